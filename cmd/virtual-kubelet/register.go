@@ -11,7 +11,11 @@ func registerPodman(s *provider.Store) {
 			cfg.ConfigPath,
 			cfg.NodeName,
 			cfg.OperatingSystem,
+			cfg.InternalIP,
+			cfg.DaemonPort,
+			cfg.KubeClusterDomain,
 			cfg.ResourceManager,
+			cfg.EventRecorder,
 		)
 	})
 }
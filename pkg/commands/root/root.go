@@ -102,11 +102,31 @@ func runRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 	secretInformer := scmInformerFactory.Core().V1().Secrets()
 	configMapInformer := scmInformerFactory.Core().V1().ConfigMaps()
 	serviceInformer := scmInformerFactory.Core().V1().Services()
+	serviceAccountInformer := scmInformerFactory.Core().V1().ServiceAccounts()
 
-	rm, err := manager.NewResourceManager(podInformer.Lister(), secretInformer.Lister(), configMapInformer.Lister(), serviceInformer.Lister())
+	// Create an informer factory for the virtual node itself, so the provider sees labels set through the API.
+	nodeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(
+		client,
+		c.InformerResyncPeriod,
+		kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", c.NodeName).String()
+		}))
+	nodeInformer := nodeInformerFactory.Core().V1().Nodes()
+
+	rm, err := manager.NewResourceManager(podInformer.Lister(), secretInformer.Lister(), configMapInformer.Lister(), serviceInformer.Lister(), serviceAccountInformer.Lister(), nodeInformer.Lister(),
+		podInformer.Informer().HasSynced, nodeInformer.Informer().HasSynced)
 	if err != nil {
 		return errors.Wrap(err, "could not create resource manager")
 	}
+	// Pod lister keeps serving the last known pods while the API server is unreachable.
+	rm.SetHealthCheck(func() error {
+		return client.Discovery().RESTClient().Get().AbsPath("/healthz").Do().Error()
+	})
+
+	eb := record.NewBroadcaster()
+	eb.StartLogging(log.G(ctx).Infof)
+	eb.StartRecordingToSink(&corev1client.EventSinkImpl{Interface: client.CoreV1().Events(c.KubeNamespace)})
+	recorder := eb.NewRecorder(scheme.Scheme, corev1.EventSource{Component: path.Join(c.NodeName, "pod-controller")})
 
 	initConfig := provider.InitConfig{
 		ConfigPath:        c.ProviderConfigPath,
@@ -116,6 +136,7 @@ func runRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 		DaemonPort:        int32(c.ListenPort),
 		InternalIP:        os.Getenv("VKUBELET_POD_IP"),
 		KubeClusterDomain: c.KubeClusterDomain,
+		EventRecorder:     recorder,
 	}
 
 	pInit := s.Get(c.Provider)
@@ -166,14 +187,10 @@ func runRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 		log.G(ctx).Fatal(err)
 	}
 
-	eb := record.NewBroadcaster()
-	eb.StartLogging(log.G(ctx).Infof)
-	eb.StartRecordingToSink(&corev1client.EventSinkImpl{Interface: client.CoreV1().Events(c.KubeNamespace)})
-
 	pc, err := node.NewPodController(node.PodControllerConfig{
 		PodClient:         client.CoreV1(),
 		PodInformer:       podInformer,
-		EventRecorder:     eb.NewRecorder(scheme.Scheme, corev1.EventSource{Component: path.Join(pNode.Name, "pod-controller")}),
+		EventRecorder:     recorder,
 		Provider:          p,
 		SecretInformer:    secretInformer,
 		ConfigMapInformer: configMapInformer,
@@ -185,6 +202,7 @@ func runRootCommand(ctx context.Context, s *provider.Store, c Opts) error {
 
 	go podInformerFactory.Start(ctx.Done())
 	go scmInformerFactory.Start(ctx.Done())
+	go nodeInformerFactory.Start(ctx.Done())
 
 	go func() {
 		if err := pc.Run(ctx, c.PodSyncWorkers); err != nil && errors.Cause(err) != context.Canceled {
@@ -4,8 +4,8 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/ghodss/yaml"
 	v1 "k8s.io/api/core/v1"
@@ -14,6 +14,23 @@ import (
 	"github.com/virtual-kubelet/podman/pkg/iopodman"
 )
 
+// ContainerIDPrefix is runtime prefix of the container ID reported in
+// container status
+const ContainerIDPrefix = "podman://"
+
+const (
+	// ManagedByLabel marks podman pods created by the provider, its value
+	// is name of the node running the pod
+	ManagedByLabel = "io.virtual-kubelet.managed-by"
+	// PodUIDLabel is label of podman pod with UID of the kubernetes pod
+	PodUIDLabel = "io.kubernetes.pod.uid"
+)
+
+// StopSignalAnnotationPrefix prefixes pod annotation with custom stop signal
+// of the container, e.g. stop-signal.podman.virtual-kubelet.io/app: SIGQUIT.
+// Image stop signal is used by default
+const StopSignalAnnotationPrefix = "stop-signal.podman.virtual-kubelet.io/"
+
 func BuildKeyFromNames(namespace string, name string) (string, error) {
 	return fmt.Sprintf("%s-%s", namespace, name), nil
 }
@@ -31,25 +48,29 @@ func SplitPodName(key string) (namespace, name string) {
 	return keys[0], keys[1]
 }
 
+// BuildContainerName returns podman container name for the kubernetes
+// container running in the podman pod
+func BuildContainerName(podName, containerName string) string {
+	return fmt.Sprintf("%s-%s", podName, containerName)
+}
+
+// BuildVolumeName returns podman volume name for the kubernetes volume of
+// the podman pod
+func BuildVolumeName(podName, volumeName string) string {
+	return fmt.Sprintf("%s-%s", podName, volumeName)
+}
+
 // KubeSpecToPodmanContainer converts v1.Container to podman.Create spec. pod
 // argument is used to configure volumes and external configuration to container
-func KubeSpecToPodmanContainer(pod v1.Pod, container v1.Container, podName string) iopodman.Create {
+func KubeSpecToPodmanContainer(pod v1.Pod, container v1.Container, podName string, sources map[string]string, env []string) iopodman.Create {
 	// TODO: Extend this to match most of the fields
 	var args []string
 	args = append(args, container.Image)
 	args = append(args, container.Command...)
 	args = append(args, container.Args...)
-	containerName := fmt.Sprintf("%s-%s", podName, container.Name)
+	containerName := BuildContainerName(podName, container.Name)
 
-	// construct hostPath pairs for mount
-	var volumes []string
-	for _, podVolume := range pod.Spec.Volumes {
-		for _, containerVolume := range container.VolumeMounts {
-			if podVolume.HostPath != nil && podVolume.Name == containerVolume.Name {
-				volumes = append(volumes, fmt.Sprintf("%s:%s", podVolume.HostPath.Path, containerVolume.MountPath))
-			}
-		}
-	}
+	volumes := getVolumes(pod, container, sources)
 
 	podmanPod := iopodman.Create{
 		Args:    args,
@@ -59,26 +80,56 @@ func KubeSpecToPodmanContainer(pod v1.Pod, container v1.Container, podName strin
 		Volume:  &volumes,
 	}
 
-	if container.SecurityContext != nil {
-		podmanPod.Privileged = container.SecurityContext.Privileged
-		// if we want to interact with undelaying hostPath
-		// TODO: make it separate configurable
-		podmanPod.Tty = container.SecurityContext.Privileged
-	}
+	setResources(container, &podmanPod)
+	setSecurityContext(pod, container, &podmanPod)
 
-	if pod.Spec.HostNetwork {
-		podmanPod.Net = StringPtr("host")
+	if container.TTY {
+		podmanPod.Tty = &container.TTY
 	}
 
-	var vars []string
-	for _, e := range pod.Spec.Containers[0].Env {
-		vars = append(vars, fmt.Sprintf("%s=%s", e.Name, e.Value))
+	setNamespaces(pod, &podmanPod)
+
+	podmanPod.Env = &env
+
+	if signal := pod.Annotations[StopSignalAnnotationPrefix+container.Name]; signal != "" {
+		podmanPod.StopSignal = &signal
 	}
-	podmanPod.Env = &vars
 
 	return podmanPod
 }
 
+// getVolumes constructs source:destination pairs for container volume mounts.
+// sources holds node path of each prepared pod volume
+func getVolumes(pod v1.Pod, container v1.Container, sources map[string]string) []string {
+	var volumes []string
+	for _, mount := range container.VolumeMounts {
+		source, ok := sources[mount.Name]
+		if !ok {
+			continue
+		}
+		if mount.SubPath != "" {
+			source = filepath.Join(source, mount.SubPath)
+		}
+		volume := fmt.Sprintf("%s:%s", source, mount.MountPath)
+		if mount.ReadOnly || isReadOnlyVolume(pod, mount.Name) {
+			volume += ":ro"
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes
+}
+
+// isReadOnlyVolume returns true for volumes which content is managed by
+// kubernetes and can't be changed by containers
+func isReadOnlyVolume(pod v1.Pod, name string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == name {
+			return volume.ConfigMap != nil || volume.Secret != nil
+		}
+	}
+	return false
+}
+
 // GetPodmanPod return podmanPod with v1.Pod metadata in the label
 func GetPodmanPod(key string, p *v1.Pod) (*iopodman.PodCreate, error) {
 	// preserve original pod spec into lables
@@ -92,18 +143,78 @@ func GetPodmanPod(key string, p *v1.Pod) (*iopodman.PodCreate, error) {
 		pod.Labels = make(map[string]string, 1)
 	}
 	pod.Labels["pod"] = podSpecBase
+	pod.Labels[ManagedByLabel] = pod.Spec.NodeName
+	pod.Labels[PodUIDLabel] = string(pod.UID)
 
 	podmanPod := iopodman.PodCreate{
 		Name:   key,
 		Labels: pod.Labels,
+		Infra:  true,
+	}
+	podmanPod.Share = getShare(pod)
+	if !pod.Spec.HostNetwork {
+		podmanPod.Publish = getPublish(pod)
 	}
 
 	return &podmanPod, nil
 }
 
-// GetKubePod returns v1.Pod from podman pod json
-// Kuberentes spec is cached in the podman labels
-func GetKubePod(podmanJSON string) (*v1.Pod, error) {
+// getShare returns namespaces the pod containers share with the infra
+// container. Network and ipc are shared like in kubelet sandbox unless the
+// pod uses host namespaces, pid is shared only with shareProcessNamespace.
+// Uts is not shared, so containers can set the pod hostname
+func getShare(pod *v1.Pod) []string {
+	var share []string
+	if !pod.Spec.HostNetwork {
+		share = append(share, "net")
+	}
+	if !pod.Spec.HostIPC {
+		share = append(share, "ipc")
+	}
+	if pod.Spec.ShareProcessNamespace != nil && *pod.Spec.ShareProcessNamespace && !pod.Spec.HostPID {
+		share = append(share, "pid")
+	}
+	return share
+}
+
+// setNamespaces sets host namespaces of the container requested by the pod
+func setNamespaces(pod v1.Pod, create *iopodman.Create) {
+	if pod.Spec.HostNetwork {
+		create.Net = StringPtr("host")
+		create.Uts = StringPtr("host")
+	}
+	if pod.Spec.HostPID {
+		create.Pid = StringPtr("host")
+	}
+	if pod.Spec.HostIPC {
+		create.Ipc = StringPtr("host")
+	}
+}
+
+// getPublish returns host ports of the pod containers in the podman publish
+// format hostIP:hostPort:containerPort/protocol. Ports are published by the
+// infra container holding the pod network namespace
+func getPublish(pod *v1.Pod) []string {
+	var publish []string
+	containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		for _, port := range c.Ports {
+			if port.HostPort == 0 {
+				continue
+			}
+			protocol := strings.ToLower(string(port.Protocol))
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			publish = append(publish, fmt.Sprintf("%s:%d:%d/%s", port.HostIP, port.HostPort, port.ContainerPort, protocol))
+		}
+	}
+	return publish
+}
+
+// GetKubePod returns v1.Pod from podman pod json and inspect json of the
+// pod containers. Kuberentes spec is cached in the podman labels
+func GetKubePod(podmanJSON string, containersJSON []string) (*v1.Pod, error) {
 	var pPod PodmanPod
 	err := json.Unmarshal([]byte(podmanJSON), &pPod)
 	if err != nil {
@@ -120,8 +231,18 @@ func GetKubePod(podmanJSON string) (*v1.Pod, error) {
 		return nil, err
 	}
 
+	containers := make([]PodmanContainer, 0, len(containersJSON))
+	for _, containerJSON := range containersJSON {
+		var c PodmanContainer
+		err = json.Unmarshal([]byte(containerJSON), &c)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, c)
+	}
+
 	// configure status for the kubePod
-	kpod.Status, err = GetPodStatus(pPod)
+	kpod.Status, err = GetPodStatus(&kpod, pPod, containers)
 	if err != nil {
 		return nil, err
 	}
@@ -139,13 +260,26 @@ func MarshalPodPod(podmanJSON string) (*PodmanPod, error) {
 	return pPod, nil
 }
 
+// GetPodIP returns IP address of the pod. Pod network namespace is held by
+// the infra container
+func GetPodIP(containers []PodmanContainer) string {
+	for _, c := range containers {
+		if c.IsInfra {
+			if c.NetworkSettings.IPAddress != "" {
+				return c.NetworkSettings.IPAddress
+			}
+			return c.NetworkSettings.GlobalIPv6Address
+		}
+	}
+	return ""
+}
+
 // GetPodStatus returns v1.PodStatus from PodmanPod spec
-func GetPodStatus(pPod PodmanPod) (v1.PodStatus, error) {
-	now := metav1.NewTime(time.Now())
+func GetPodStatus(pod *v1.Pod, pPod PodmanPod, containers []PodmanContainer) (v1.PodStatus, error) {
+	created := metav1.NewTime(pPod.Config.Created)
 	status := v1.PodStatus{}
-	status.StartTime = &now
-	status.HostIP = "1.2.3.4"
-	status.PodIP = "5.6.7.8"
+	status.StartTime = &created
+	status.PodIP = GetPodIP(containers)
 	status.Conditions = []v1.PodCondition{
 		{
 			Type:   v1.PodInitialized,
@@ -153,7 +287,11 @@ func GetPodStatus(pPod PodmanPod) (v1.PodStatus, error) {
 		},
 		{
 			Type:   v1.PodReady,
-			Status: v1.ConditionTrue,
+			Status: v1.ConditionFalse,
+		},
+		{
+			Type:   v1.ContainersReady,
+			Status: v1.ConditionFalse,
 		},
 		{
 			Type:   v1.PodScheduled,
@@ -161,51 +299,282 @@ func GetPodStatus(pPod PodmanPod) (v1.PodStatus, error) {
 		},
 	}
 
-	for _, c := range pPod.Containers {
-		containerStatus := v1.ContainerStatus{}
-		containerStatus.Name = c.ID
-		containerStatus.Image = c.ID
-		var state v1.ContainerState
-		switch c.State {
-		case "running":
-			state = v1.ContainerState{
-				Running: &v1.ContainerStateRunning{
-					StartedAt: metav1.Time{
-						Time: pPod.Config.Created,
-					},
+	initialized, initFailed, initStatuses := getInitContainerStatuses(pod, pPod.Config.Name, containers)
+	status.InitContainerStatuses = initStatuses
+
+	// infra container is not part of the pod spec, so it is not reported
+	for _, spec := range pod.Spec.Containers {
+		containerStatus := v1.ContainerStatus{
+			Name:  spec.Name,
+			Image: spec.Image,
+			State: v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{
+					Reason: "ContainerCreating",
 				},
-			}
-			containerStatus.Ready = true
-			status.Phase = v1.PodRunning
-		case "exited":
-			state = v1.ContainerState{
-				Terminated: &v1.ContainerStateTerminated{
-					StartedAt: metav1.Time{
-						Time: time.Now(),
-					},
+			},
+		}
+		if c := FindContainer(containers, BuildContainerName(pPod.Config.Name, spec.Name)); c != nil {
+			containerStatus = getContainerStatus(spec, *c)
+		}
+		status.ContainerStatuses = append(status.ContainerStatuses, containerStatus)
+	}
+	status.Phase = getPodPhase(pod, status.ContainerStatuses)
+
+	if !initialized {
+		setPodInitializing(pod, &status, initFailed)
+	}
+	SetReadyConditions(pod, &status)
+
+	return status, nil
+}
+
+// SetReadyConditions sets pod Ready and ContainersReady conditions from
+// readiness of the pod containers, the same way kubelet does
+func SetReadyConditions(pod *v1.Pod, status *v1.PodStatus) {
+	ready := v1.PodCondition{Status: v1.ConditionTrue}
+	initialized := getCondition(status, v1.PodInitialized)
+
+	var unknown, unready []string
+	for _, spec := range pod.Spec.Containers {
+		s := findContainerStatus(status.ContainerStatuses, spec.Name)
+		if s == nil {
+			unknown = append(unknown, spec.Name)
+		} else if !s.Ready {
+			unready = append(unready, spec.Name)
+		}
+	}
+
+	switch {
+	case initialized != nil && initialized.Status != v1.ConditionTrue:
+		ready.Status = v1.ConditionFalse
+		ready.Reason = initialized.Reason
+		ready.Message = initialized.Message
+	case status.Phase == v1.PodSucceeded && len(unknown) == 0:
+		ready.Status = v1.ConditionFalse
+		ready.Reason = "PodCompleted"
+	case len(unknown) > 0 || len(unready) > 0:
+		var messages []string
+		if len(unknown) > 0 {
+			messages = append(messages, fmt.Sprintf("containers with unknown status: %v", unknown))
+		}
+		if len(unready) > 0 {
+			messages = append(messages, fmt.Sprintf("containers with unready status: %v", unready))
+		}
+		ready.Status = v1.ConditionFalse
+		ready.Reason = "ContainersNotReady"
+		ready.Message = strings.Join(messages, ", ")
+	}
+
+	for _, t := range []v1.PodConditionType{v1.PodReady, v1.ContainersReady} {
+		if c := getCondition(status, t); c != nil {
+			c.Status = ready.Status
+			c.Reason = ready.Reason
+			c.Message = ready.Message
+		}
+	}
+}
+
+// getCondition returns pod condition of the given type or nil
+func getCondition(status *v1.PodStatus, t v1.PodConditionType) *v1.PodCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == t {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// findContainerStatus returns status of the container with the given name
+// or nil
+func findContainerStatus(statuses []v1.ContainerStatus, name string) *v1.ContainerStatus {
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// getInitContainerStatuses returns statuses of the pod init containers. It
+// reports if all init containers completed and if any of them failed
+func getInitContainerStatuses(pod *v1.Pod, podName string, containers []PodmanContainer) (initialized, failed bool, statuses []v1.ContainerStatus) {
+	initialized = true
+	for _, spec := range pod.Spec.InitContainers {
+		containerStatus := v1.ContainerStatus{
+			Name:  spec.Name,
+			Image: spec.Image,
+			State: v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{
+					Reason: "PodInitializing",
 				},
-			}
-			status.Phase = v1.PodFailed
-			containerStatus.Ready = false
-		default:
-			state = v1.ContainerState{
-				Terminated: &v1.ContainerStateTerminated{
-					StartedAt: metav1.Time{
-						Time: time.Now(),
-					},
+			},
+		}
+		if c := FindContainer(containers, BuildContainerName(podName, spec.Name)); c != nil {
+			containerStatus = getContainerStatus(spec, *c)
+		}
+
+		terminated := containerStatus.State.Terminated
+		containerStatus.Ready = terminated != nil && terminated.ExitCode == 0
+		if !containerStatus.Ready {
+			initialized = false
+		}
+		if terminated != nil && terminated.ExitCode != 0 {
+			failed = true
+		}
+		statuses = append(statuses, containerStatus)
+	}
+	return initialized, failed, statuses
+}
+
+// setPodInitializing updates status of the pod, which init containers did
+// not complete yet. App containers are not created in this phase
+func setPodInitializing(pod *v1.Pod, status *v1.PodStatus, failed bool) {
+	var pending []string
+	for _, s := range status.InitContainerStatuses {
+		if s.State.Terminated == nil || s.State.Terminated.ExitCode != 0 {
+			pending = append(pending, s.Name)
+		}
+	}
+	message := fmt.Sprintf("containers with incomplete status: %v", pending)
+
+	status.Phase = v1.PodPending
+	if failed && pod.Spec.RestartPolicy == v1.RestartPolicyNever {
+		status.Phase = v1.PodFailed
+	}
+	if c := getCondition(status, v1.PodInitialized); c != nil {
+		c.Status = v1.ConditionFalse
+		c.Reason = "ContainersNotInitialized"
+		c.Message = message
+	}
+
+	status.ContainerStatuses = nil
+	for _, spec := range pod.Spec.Containers {
+		status.ContainerStatuses = append(status.ContainerStatuses, v1.ContainerStatus{
+			Name:  spec.Name,
+			Image: spec.Image,
+			State: v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{
+					Reason: "PodInitializing",
 				},
+			},
+		})
+	}
+}
+
+// getContainerStatus returns status of kubernetes container from podman
+// container inspect data
+func getContainerStatus(spec v1.Container, c PodmanContainer) v1.ContainerStatus {
+	containerStatus := v1.ContainerStatus{
+		Name:        spec.Name,
+		Image:       spec.Image,
+		ImageID:     c.Image,
+		ContainerID: ContainerIDPrefix + c.ID,
+	}
+	if c.ImageName != "" {
+		containerStatus.Image = c.ImageName
+	}
+
+	switch c.State.Status {
+	case "running":
+		containerStatus.State.Running = &v1.ContainerStateRunning{
+			StartedAt: metav1.NewTime(c.State.StartedAt),
+		}
+		containerStatus.Ready = true
+	case "exited", "stopped":
+		reason := "Completed"
+		switch {
+		case c.State.OOMKilled:
+			reason = "OOMKilled"
+		case c.State.ExitCode != 0:
+			reason = "Error"
+		}
+		containerStatus.State.Terminated = &v1.ContainerStateTerminated{
+			ExitCode:    int32(c.State.ExitCode),
+			Reason:      reason,
+			Message:     c.State.Error,
+			StartedAt:   metav1.NewTime(c.State.StartedAt),
+			FinishedAt:  metav1.NewTime(c.State.FinishedAt),
+			ContainerID: ContainerIDPrefix + c.ID,
+		}
+	default:
+		containerStatus.State.Waiting = &v1.ContainerStateWaiting{
+			Reason: "ContainerCreating",
+		}
+	}
+	return containerStatus
+}
+
+// getPodPhase returns pod phase from container statuses and pod restart
+// policy, the same way kubelet does
+func getPodPhase(pod *v1.Pod, statuses []v1.ContainerStatus) v1.PodPhase {
+	var pending, running, stopped, succeeded int
+	for _, s := range statuses {
+		switch {
+		case s.State.Running != nil:
+			running++
+		case s.State.Terminated != nil:
+			stopped++
+			if s.State.Terminated.ExitCode == 0 {
+				succeeded++
 			}
-			status.Phase = v1.PodUnknown
-			containerStatus.Ready = false
+		case s.LastTerminationState.Terminated != nil:
+			// container is waiting for restart
+			stopped++
+		default:
+			pending++
 		}
-		containerStatus.State = state
-		status.ContainerStatuses = append(status.ContainerStatuses, containerStatus)
 	}
 
-	return status, nil
+	switch {
+	case pending > 0:
+		return v1.PodPending
+	case running > 0:
+		return v1.PodRunning
+	case stopped > 0:
+		if pod.Spec.RestartPolicy == v1.RestartPolicyAlways {
+			return v1.PodRunning
+		}
+		if stopped == succeeded {
+			return v1.PodSucceeded
+		}
+		if pod.Spec.RestartPolicy == v1.RestartPolicyNever {
+			return v1.PodFailed
+		}
+		return v1.PodRunning
+	default:
+		return v1.PodPending
+	}
+}
+
+// ShouldRestart returns true if container exited with exit code should be
+// restarted according to the pod restart policy
+func ShouldRestart(pod *v1.Pod, exitCode int) bool {
+	switch pod.Spec.RestartPolicy {
+	case v1.RestartPolicyNever:
+		return false
+	case v1.RestartPolicyOnFailure:
+		return exitCode != 0
+	default:
+		return true
+	}
+}
+
+// FindContainer returns container with the given name or nil
+func FindContainer(containers []PodmanContainer, name string) *PodmanContainer {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
 }
 
 // StringPtr returns pointer string
 func StringPtr(s string) *string {
 	return &s
 }
+
+// Int64Ptr returns pointer int64
+func Int64Ptr(i int64) *int64 {
+	return &i
+}
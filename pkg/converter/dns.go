@@ -0,0 +1,213 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+)
+
+const (
+	// maxDNSNameservers and maxDNSSearches are resolver limits, same as
+	// enforced by kubelet
+	maxDNSNameservers = 3
+	maxDNSSearches    = 6
+	// maxHostnameLength is maximal length of the pod hostname
+	maxHostnameLength = 63
+)
+
+// DNSConfig is resolver configuration of the pod containers
+type DNSConfig struct {
+	Nameservers []string
+	Searches    []string
+	Options     []string
+}
+
+// GetDNSConfig returns resolver configuration of the pod according to its
+// dnsPolicy and dnsConfig, the same way kubelet builds it. Node is resolver
+// configuration of the node. Cluster policies fall back to the node
+// resolver if cluster DNS is not configured
+func GetDNSConfig(pod *v1.Pod, clusterDomain string, clusterDNS []string, node DNSConfig) DNSConfig {
+	var config DNSConfig
+	policy := pod.Spec.DNSPolicy
+	clusterFirst := policy == v1.DNSClusterFirstWithHostNet ||
+		(policy == v1.DNSClusterFirst || policy == "") && !pod.Spec.HostNetwork
+	switch {
+	case policy == v1.DNSNone:
+	case clusterFirst && len(clusterDNS) > 0:
+		config.Nameservers = append(config.Nameservers, clusterDNS...)
+		if clusterDomain != "" {
+			config.Searches = []string{
+				fmt.Sprintf("%s.svc.%s", pod.Namespace, clusterDomain),
+				fmt.Sprintf("svc.%s", clusterDomain),
+				clusterDomain,
+			}
+		}
+		config.Searches = append(config.Searches, node.Searches...)
+		config.Options = []string{"ndots:5"}
+	default:
+		config.Nameservers = append(config.Nameservers, node.Nameservers...)
+		config.Searches = append(config.Searches, node.Searches...)
+		config.Options = append(config.Options, node.Options...)
+	}
+
+	if dnsConfig := pod.Spec.DNSConfig; dnsConfig != nil {
+		config.Nameservers = appendUnique(config.Nameservers, dnsConfig.Nameservers...)
+		config.Searches = appendUnique(config.Searches, dnsConfig.Searches...)
+		for _, o := range dnsConfig.Options {
+			option := o.Name
+			if o.Value != nil {
+				option += ":" + *o.Value
+			}
+			config.Options = mergeOption(config.Options, o.Name, option)
+		}
+	}
+
+	if len(config.Nameservers) > maxDNSNameservers {
+		config.Nameservers = config.Nameservers[:maxDNSNameservers]
+	}
+	if len(config.Searches) > maxDNSSearches {
+		config.Searches = config.Searches[:maxDNSSearches]
+	}
+	return config
+}
+
+// ParseResolvConf returns resolver configuration of resolv.conf file
+func ParseResolvConf(data []byte) DNSConfig {
+	var config DNSConfig
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			config.Nameservers = append(config.Nameservers, fields[1])
+		case "search":
+			config.Searches = fields[1:]
+		case "options":
+			config.Options = append(config.Options, fields[1:]...)
+		}
+	}
+	return config
+}
+
+// ResolvConf returns content of the resolv.conf file with the configuration
+func (c DNSConfig) ResolvConf() []byte {
+	var b bytes.Buffer
+	for _, ns := range c.Nameservers {
+		fmt.Fprintf(&b, "nameserver %s\n", ns)
+	}
+	if len(c.Searches) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(c.Searches, " "))
+	}
+	if len(c.Options) > 0 {
+		fmt.Fprintf(&b, "options %s\n", strings.Join(c.Options, " "))
+	}
+	return b.Bytes()
+}
+
+// GetHostname returns hostname and domain of the pod, same as set by
+// kubelet. Domain is empty if pod has no subdomain
+func GetHostname(pod *v1.Pod, clusterDomain string) (string, string) {
+	hostname := pod.Name
+	if pod.Spec.Hostname != "" {
+		hostname = pod.Spec.Hostname
+	}
+	if len(hostname) > maxHostnameLength {
+		hostname = strings.TrimRight(hostname[:maxHostnameLength], "-.")
+	}
+	var domain string
+	if pod.Spec.Subdomain != "" {
+		domain = fmt.Sprintf("%s.%s.svc.%s", pod.Spec.Subdomain, pod.Namespace, clusterDomain)
+	}
+	return hostname, domain
+}
+
+// HostsFile returns content of the /etc/hosts file of the pod, same as
+// managed by kubelet. Pod hostname resolves to the pod IP, so hostname -f
+// returns the fully qualified name of the pod
+func HostsFile(pod *v1.Pod, podIP, clusterDomain string) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Kubernetes-managed hosts file.\n")
+	b.WriteString("127.0.0.1\tlocalhost\n")
+	b.WriteString("::1\tlocalhost ip6-localhost ip6-loopback\n")
+	b.WriteString("fe00::0\tip6-localnet\n")
+	b.WriteString("fe00::0\tip6-mcastprefix\n")
+	b.WriteString("fe00::1\tip6-allnodes\n")
+	b.WriteString("fe00::2\tip6-allrouters\n")
+	if podIP != "" {
+		hostname, domain := GetHostname(pod, clusterDomain)
+		if domain != "" {
+			fmt.Fprintf(&b, "%s\t%s.%s\t%s\n", podIP, hostname, domain, hostname)
+		} else {
+			fmt.Fprintf(&b, "%s\t%s\n", podIP, hostname)
+		}
+	}
+	if len(pod.Spec.HostAliases) > 0 {
+		b.WriteString("\n# Entries added by HostAliases.\n")
+		for _, alias := range pod.Spec.HostAliases {
+			fmt.Fprintf(&b, "%s\t%s\n", alias.IP, strings.Join(alias.Hostnames, "\t"))
+		}
+	}
+	return b.Bytes()
+}
+
+// SetHostNetworkDNS sets resolver and host aliases of the container in the
+// host network. Podman applies them on top of the node files
+func SetHostNetworkDNS(pod *v1.Pod, config DNSConfig, create *iopodman.Create) {
+	if len(config.Nameservers) > 0 {
+		create.Dns = &config.Nameservers
+	}
+	if len(config.Searches) > 0 {
+		create.DnsSearch = &config.Searches
+	}
+	if len(config.Options) > 0 {
+		create.DnsOpt = &config.Options
+	}
+	var hosts []string
+	for _, alias := range pod.Spec.HostAliases {
+		for _, hostname := range alias.Hostnames {
+			hosts = append(hosts, hostname+":"+alias.IP)
+		}
+	}
+	if len(hosts) > 0 {
+		create.AddHost = &hosts
+	}
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, existing := range list {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// mergeOption replaces resolver option of the same name or appends it
+func mergeOption(options []string, name, option string) []string {
+	for i, o := range options {
+		if strings.SplitN(o, ":", 2)[0] == name {
+			options[i] = option
+			return options
+		}
+	}
+	return append(options, option)
+}
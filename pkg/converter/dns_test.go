@@ -0,0 +1,253 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func dnsTestPod(spec v1.PodSpec) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       spec,
+	}
+}
+
+func TestGetDNSConfig(t *testing.T) {
+	node := DNSConfig{
+		Nameservers: []string{"192.168.0.1"},
+		Searches:    []string{"lan"},
+		Options:     []string{"ndots:1", "rotate"},
+	}
+	ndots := "2"
+	tests := []struct {
+		name       string
+		spec       v1.PodSpec
+		clusterDNS []string
+		want       DNSConfig
+	}{
+		{
+			name:       "cluster first",
+			clusterDNS: []string{"10.96.0.10"},
+			want: DNSConfig{
+				Nameservers: []string{"10.96.0.10"},
+				Searches:    []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local", "lan"},
+				Options:     []string{"ndots:5"},
+			},
+		},
+		{
+			name: "cluster first without cluster dns",
+			want: node,
+		},
+		{
+			name:       "cluster first in host network",
+			spec:       v1.PodSpec{HostNetwork: true},
+			clusterDNS: []string{"10.96.0.10"},
+			want:       node,
+		},
+		{
+			name:       "cluster first with host net",
+			spec:       v1.PodSpec{HostNetwork: true, DNSPolicy: v1.DNSClusterFirstWithHostNet},
+			clusterDNS: []string{"10.96.0.10"},
+			want: DNSConfig{
+				Nameservers: []string{"10.96.0.10"},
+				Searches:    []string{"default.svc.cluster.local", "svc.cluster.local", "cluster.local", "lan"},
+				Options:     []string{"ndots:5"},
+			},
+		},
+		{
+			name:       "default",
+			spec:       v1.PodSpec{DNSPolicy: v1.DNSDefault},
+			clusterDNS: []string{"10.96.0.10"},
+			want:       node,
+		},
+		{
+			name: "none with dns config",
+			spec: v1.PodSpec{
+				DNSPolicy: v1.DNSNone,
+				DNSConfig: &v1.PodDNSConfig{
+					Nameservers: []string{"1.1.1.1", "1.1.1.1"},
+					Searches:    []string{"example.com"},
+					Options:     []v1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}, {Name: "edns0"}},
+				},
+			},
+			want: DNSConfig{
+				Nameservers: []string{"1.1.1.1"},
+				Searches:    []string{"example.com"},
+				Options:     []string{"ndots:2", "edns0"},
+			},
+		},
+		{
+			name: "dns config overrides option",
+			spec: v1.PodSpec{
+				DNSPolicy: v1.DNSDefault,
+				DNSConfig: &v1.PodDNSConfig{
+					Options: []v1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}},
+				},
+			},
+			want: DNSConfig{
+				Nameservers: []string{"192.168.0.1"},
+				Searches:    []string{"lan"},
+				Options:     []string{"ndots:2", "rotate"},
+			},
+		},
+		{
+			name: "limits",
+			spec: v1.PodSpec{
+				DNSPolicy: v1.DNSNone,
+				DNSConfig: &v1.PodDNSConfig{
+					Nameservers: []string{"1.1.1.1", "1.1.1.2", "1.1.1.3", "1.1.1.4"},
+					Searches:    []string{"a", "b", "c", "d", "e", "f", "g"},
+				},
+			},
+			want: DNSConfig{
+				Nameservers: []string{"1.1.1.1", "1.1.1.2", "1.1.1.3"},
+				Searches:    []string{"a", "b", "c", "d", "e", "f"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// node configuration must not be modified
+			n := DNSConfig{
+				Nameservers: append([]string(nil), node.Nameservers...),
+				Searches:    append([]string(nil), node.Searches...),
+				Options:     append([]string(nil), node.Options...),
+			}
+			got := GetDNSConfig(dnsTestPod(tt.spec), "cluster.local", tt.clusterDNS, n)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(n, node) {
+				t.Errorf("node got %v, want %v", n, node)
+			}
+		})
+	}
+}
+
+func TestParseResolvConf(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want DNSConfig
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "full",
+			data: "# generated\nnameserver 10.0.0.1\nnameserver 10.0.0.2 ; second\nsearch example.com lan\noptions ndots:2 rotate\n",
+			want: DNSConfig{
+				Nameservers: []string{"10.0.0.1", "10.0.0.2"},
+				Searches:    []string{"example.com", "lan"},
+				Options:     []string{"ndots:2", "rotate"},
+			},
+		},
+		{
+			name: "last search wins",
+			data: "search a\nsearch b c\nnameserver\n",
+			want: DNSConfig{Searches: []string{"b", "c"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseResolvConf([]byte(tt.data)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvConf(t *testing.T) {
+	config := DNSConfig{
+		Nameservers: []string{"10.0.0.1"},
+		Searches:    []string{"a", "b"},
+		Options:     []string{"ndots:5"},
+	}
+	want := "nameserver 10.0.0.1\nsearch a b\noptions ndots:5\n"
+	if got := string(config.ResolvConf()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := ParseResolvConf(config.ResolvConf()); !reflect.DeepEqual(got, config) {
+		t.Errorf("got %v, want %v", got, config)
+	}
+}
+
+func TestGetHostname(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     v1.PodSpec
+		podName  string
+		hostname string
+		domain   string
+	}{
+		{"pod name", v1.PodSpec{}, "web", "web", ""},
+		{"hostname", v1.PodSpec{Hostname: "db"}, "web", "db", ""},
+		{"subdomain", v1.PodSpec{Hostname: "db", Subdomain: "data"}, "web", "db", "data.default.svc.cluster.local"},
+		{"truncated", v1.PodSpec{}, strings.Repeat("a", 62) + "-b", strings.Repeat("a", 62), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := dnsTestPod(tt.spec)
+			pod.Name = tt.podName
+			hostname, domain := GetHostname(pod, "cluster.local")
+			if hostname != tt.hostname || domain != tt.domain {
+				t.Errorf("got %q %q, want %q %q", hostname, domain, tt.hostname, tt.domain)
+			}
+		})
+	}
+}
+
+func TestHostsFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  v1.PodSpec
+		podIP string
+		want  []string
+		skip  []string
+	}{
+		{
+			name:  "pod ip",
+			podIP: "10.88.0.5",
+			want:  []string{"127.0.0.1\tlocalhost\n", "10.88.0.5\tweb\n"},
+			skip:  []string{"HostAliases"},
+		},
+		{
+			name: "no pod ip",
+			want: []string{"127.0.0.1\tlocalhost\n"},
+			skip: []string{"\tweb"},
+		},
+		{
+			name:  "subdomain",
+			spec:  v1.PodSpec{Subdomain: "data"},
+			podIP: "10.88.0.5",
+			want:  []string{"10.88.0.5\tweb.data.default.svc.cluster.local\tweb\n"},
+		},
+		{
+			name: "host aliases",
+			spec: v1.PodSpec{HostAliases: []v1.HostAlias{
+				{IP: "10.0.0.9", Hostnames: []string{"foo", "bar"}},
+			}},
+			podIP: "10.88.0.5",
+			want:  []string{"# Entries added by HostAliases.\n10.0.0.9\tfoo\tbar\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(HostsFile(dnsTestPod(tt.spec), tt.podIP, "cluster.local"))
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("got %q, want %q", got, want)
+				}
+			}
+			for _, skip := range tt.skip {
+				if strings.Contains(got, skip) {
+					t.Errorf("got %q, do not want %q", got, skip)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+)
+
+// SetHealthcheck translates exec probe into podman healthcheck of the
+// container. Podman runs json array command without shell
+func SetHealthcheck(probe *v1.Probe, create *iopodman.Create) error {
+	if probe.Exec == nil {
+		return fmt.Errorf("only exec probe can be run as healthcheck")
+	}
+	command, err := json.Marshal(probe.Exec.Command)
+	if err != nil {
+		return err
+	}
+	create.HealthcheckCommand = StringPtr(string(command))
+
+	if probe.PeriodSeconds > 0 {
+		create.HealthcheckInterval = StringPtr(seconds(probe.PeriodSeconds))
+	}
+	if probe.TimeoutSeconds > 0 {
+		create.HealthcheckTimeout = StringPtr(seconds(probe.TimeoutSeconds))
+	}
+	if probe.InitialDelaySeconds > 0 {
+		create.HealthcheckStartPeriod = StringPtr(seconds(probe.InitialDelaySeconds))
+	}
+	if probe.FailureThreshold > 0 {
+		create.HealthcheckRetries = Int64Ptr(int64(probe.FailureThreshold))
+	}
+	return nil
+}
+
+// seconds formats probe seconds as duration accepted by podman
+func seconds(s int32) string {
+	return (time.Duration(s) * time.Second).String()
+}
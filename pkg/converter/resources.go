@@ -0,0 +1,78 @@
+package converter
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+)
+
+const (
+	// minShares, sharesPerCPU and milliCPUToCPU are the same as used by
+	// kubelet to convert cpu requests to cgroup cpu shares
+	minShares     = 2
+	sharesPerCPU  = 1024
+	milliCPUToCPU = 1000
+	// quotaPeriod is cfs period in microseconds used for cpu limits
+	quotaPeriod = 100000
+	// minQuotaPeriod is the minimal cfs quota in microseconds
+	minQuotaPeriod = 1000
+)
+
+// setResources translates container resource requests and limits into
+// cgroup settings of podman container
+func setResources(container v1.Container, create *iopodman.Create) {
+	requests := container.Resources.Requests
+	limits := container.Resources.Limits
+
+	// cpu request defaults to cpu limit, as done by api server defaulting.
+	// Containers without cpu request get minimal shares, as done by kubelet
+	cpuRequest, ok := requests[v1.ResourceCPU]
+	if !ok {
+		cpuRequest, ok = limits[v1.ResourceCPU]
+	}
+	if ok {
+		create.CpuShares = Int64Ptr(milliCPUToShares(cpuRequest.MilliValue()))
+	} else {
+		create.CpuShares = Int64Ptr(minShares)
+	}
+
+	if cpuLimit, ok := limits[v1.ResourceCPU]; ok {
+		create.CpuPeriod = Int64Ptr(quotaPeriod)
+		create.CpuQuota = Int64Ptr(milliCPUToQuota(cpuLimit.MilliValue()))
+	}
+
+	if memoryLimit, ok := limits[v1.ResourceMemory]; ok {
+		create.Memory = StringPtr(fmt.Sprintf("%d", memoryLimit.Value()))
+	}
+}
+
+// SetStorageLimit limits size of the container root filesystem by its
+// ephemeral-storage limit. Podman rejects the size option unless storage
+// driver supports quota, e.g. overlay on xfs mounted with pquota
+func SetStorageLimit(container v1.Container, create *iopodman.Create) {
+	storageLimit, ok := container.Resources.Limits[v1.ResourceEphemeralStorage]
+	if !ok {
+		return
+	}
+	create.StorageOpt = &[]string{fmt.Sprintf("size=%d", storageLimit.Value())}
+}
+
+// milliCPUToShares converts milliCPU to cpu shares
+func milliCPUToShares(milliCPU int64) int64 {
+	shares := (milliCPU * sharesPerCPU) / milliCPUToCPU
+	if shares < minShares {
+		return minShares
+	}
+	return shares
+}
+
+// milliCPUToQuota converts milliCPU to cfs quota for quotaPeriod
+func milliCPUToQuota(milliCPU int64) int64 {
+	quota := (milliCPU * quotaPeriod) / milliCPUToCPU
+	if quota < minQuotaPeriod {
+		return minQuotaPeriod
+	}
+	return quota
+}
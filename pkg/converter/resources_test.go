@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+)
+
+func TestSetResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources v1.ResourceRequirements
+		want      iopodman.Create
+	}{
+		{
+			name: "none",
+			want: iopodman.Create{CpuShares: Int64Ptr(minShares)},
+		},
+		{
+			name: "cpu request",
+			resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m")},
+			},
+			want: iopodman.Create{CpuShares: Int64Ptr(256)},
+		},
+		{
+			name: "minimal cpu",
+			resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1m")},
+			},
+			want: iopodman.Create{CpuShares: Int64Ptr(minShares), CpuPeriod: Int64Ptr(quotaPeriod), CpuQuota: Int64Ptr(minQuotaPeriod)},
+		},
+		{
+			name: "cpu request defaults to limit",
+			resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")},
+			},
+			want: iopodman.Create{CpuShares: Int64Ptr(2048), CpuPeriod: Int64Ptr(quotaPeriod), CpuQuota: Int64Ptr(200000)},
+		},
+		{
+			name: "memory and storage limits",
+			resources: v1.ResourceRequirements{
+				Limits: v1.ResourceList{
+					v1.ResourceMemory:           resource.MustParse("64Mi"),
+					v1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+				},
+			},
+			want: iopodman.Create{CpuShares: Int64Ptr(minShares), Memory: StringPtr("67108864")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got iopodman.Create
+			setResources(v1.Container{Resources: tt.resources}, &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetStorageLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits v1.ResourceList
+		want   *[]string
+	}{
+		{"no limit", nil, nil},
+		{"limit", v1.ResourceList{v1.ResourceEphemeralStorage: resource.MustParse("1Gi")}, &[]string{"size=1073741824"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got iopodman.Create
+			SetStorageLimit(v1.Container{Resources: v1.ResourceRequirements{Limits: tt.limits}}, &got)
+			if !reflect.DeepEqual(got.StorageOpt, tt.want) {
+				t.Errorf("got %v, want %v", got.StorageOpt, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+)
+
+var (
+	// netSysctlPrefixes are sysctls of the network namespace
+	netSysctlPrefixes = []string{"net."}
+	// ipcSysctlPrefixes are sysctls of the ipc namespace
+	ipcSysctlPrefixes = []string{"kernel.shm", "kernel.msg", "kernel.sem", "fs.mqueue."}
+)
+
+// setSecurityContext translates pod and container security contexts into
+// podman create options. Container settings take precedence over the pod
+// settings. Pod must be checked by ValidateSecurityContext first
+func setSecurityContext(pod v1.Pod, container v1.Container, create *iopodman.Create) {
+	podSC := podSecurityContext(&pod)
+	sc := container.SecurityContext
+	if sc == nil {
+		sc = &v1.SecurityContext{}
+	}
+
+	if uid := RunAsUser(&pod, container); uid != nil {
+		user := strconv.FormatInt(*uid, 10)
+		if gid := runAsGroup(&pod, container); gid != nil {
+			user += ":" + strconv.FormatInt(*gid, 10)
+		}
+		create.User = &user
+	}
+
+	var groups []string
+	if podSC.FSGroup != nil {
+		groups = append(groups, strconv.FormatInt(*podSC.FSGroup, 10))
+	}
+	for _, gid := range podSC.SupplementalGroups {
+		groups = append(groups, strconv.FormatInt(gid, 10))
+	}
+	if len(groups) > 0 {
+		create.Groupadd = &groups
+	}
+
+	create.Privileged = sc.Privileged
+	if sc.Capabilities != nil {
+		if len(sc.Capabilities.Add) > 0 {
+			add := capabilities(sc.Capabilities.Add)
+			create.CapAdd = &add
+		}
+		if len(sc.Capabilities.Drop) > 0 {
+			drop := capabilities(sc.Capabilities.Drop)
+			create.CapDrop = &drop
+		}
+	}
+	create.Readonly = sc.ReadOnlyRootFilesystem
+
+	var opts []string
+	if sc.AllowPrivilegeEscalation != nil && !*sc.AllowPrivilegeEscalation {
+		opts = append(opts, "no-new-privileges")
+	}
+	seLinux := sc.SELinuxOptions
+	if seLinux == nil {
+		seLinux = podSC.SELinuxOptions
+	}
+	if seLinux != nil {
+		labels := []struct{ name, value string }{
+			{"user", seLinux.User},
+			{"role", seLinux.Role},
+			{"type", seLinux.Type},
+			{"level", seLinux.Level},
+		}
+		for _, l := range labels {
+			if l.value != "" {
+				opts = append(opts, fmt.Sprintf("label=%s:%s", l.name, l.value))
+			}
+		}
+	}
+	if len(opts) > 0 {
+		create.SecurityOpt = &opts
+	}
+
+	var sysctls []string
+	for _, s := range podSC.Sysctls {
+		sysctls = append(sysctls, s.Name+"="+s.Value)
+	}
+	if len(sysctls) > 0 {
+		create.Sysctl = &sysctls
+	}
+}
+
+// ValidateSecurityContext returns error describing security context
+// settings of the pod, which podman can't honour
+func ValidateSecurityContext(pod *v1.Pod) error {
+	podSC := podSecurityContext(pod)
+	for _, s := range podSC.Sysctls {
+		switch {
+		case hasPrefix(s.Name, netSysctlPrefixes):
+			if pod.Spec.HostNetwork {
+				return fmt.Errorf("sysctl %s can't be set with hostNetwork", s.Name)
+			}
+		case hasPrefix(s.Name, ipcSysctlPrefixes):
+			if pod.Spec.HostIPC {
+				return fmt.Errorf("sysctl %s can't be set with hostIPC", s.Name)
+			}
+		default:
+			return fmt.Errorf("sysctl %s is not namespaced, it can't be set in the container", s.Name)
+		}
+	}
+
+	containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		if c.SecurityContext != nil && c.SecurityContext.ProcMount != nil && *c.SecurityContext.ProcMount != v1.DefaultProcMount {
+			return fmt.Errorf("procMount %s of container %s is not supported", *c.SecurityContext.ProcMount, c.Name)
+		}
+		uid := RunAsUser(pod, c)
+		if uid == nil && runAsGroup(pod, c) != nil {
+			return fmt.Errorf("runAsGroup of container %s is not supported without runAsUser", c.Name)
+		}
+		if RunAsNonRoot(pod, c) && uid != nil && *uid == 0 {
+			return fmt.Errorf("container %s has runAsNonRoot and runAsUser 0", c.Name)
+		}
+	}
+	return nil
+}
+
+// RunAsUser returns user ID the container runs as or nil if image user is
+// used
+func RunAsUser(pod *v1.Pod, container v1.Container) *int64 {
+	if container.SecurityContext != nil && container.SecurityContext.RunAsUser != nil {
+		return container.SecurityContext.RunAsUser
+	}
+	return podSecurityContext(pod).RunAsUser
+}
+
+// RunAsNonRoot returns true if the container must not run as root
+func RunAsNonRoot(pod *v1.Pod, container v1.Container) bool {
+	nonRoot := podSecurityContext(pod).RunAsNonRoot
+	if container.SecurityContext != nil && container.SecurityContext.RunAsNonRoot != nil {
+		nonRoot = container.SecurityContext.RunAsNonRoot
+	}
+	return nonRoot != nil && *nonRoot
+}
+
+func runAsGroup(pod *v1.Pod, container v1.Container) *int64 {
+	if container.SecurityContext != nil && container.SecurityContext.RunAsGroup != nil {
+		return container.SecurityContext.RunAsGroup
+	}
+	return podSecurityContext(pod).RunAsGroup
+}
+
+// podSecurityContext returns security context of the pod, which is never nil
+func podSecurityContext(pod *v1.Pod) *v1.PodSecurityContext {
+	if pod.Spec.SecurityContext == nil {
+		return &v1.PodSecurityContext{}
+	}
+	return pod.Spec.SecurityContext
+}
+
+// capabilities returns capability names in the form used by podman
+func capabilities(caps []v1.Capability) []string {
+	var names []string
+	for _, c := range caps {
+		name := strings.ToUpper(string(c))
+		if name != "ALL" && !strings.HasPrefix(name, "CAP_") {
+			name = "CAP_" + name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func hasPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
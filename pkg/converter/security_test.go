@@ -0,0 +1,162 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+)
+
+func TestSetSecurityContext(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		pod  *v1.PodSecurityContext
+		sc   *v1.SecurityContext
+		want iopodman.Create
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "pod user and group",
+			pod:  &v1.PodSecurityContext{RunAsUser: Int64Ptr(1000), RunAsGroup: Int64Ptr(3000)},
+			want: iopodman.Create{User: StringPtr("1000:3000")},
+		},
+		{
+			name: "container user overrides pod",
+			pod:  &v1.PodSecurityContext{RunAsUser: Int64Ptr(1000)},
+			sc:   &v1.SecurityContext{RunAsUser: Int64Ptr(2000)},
+			want: iopodman.Create{User: StringPtr("2000")},
+		},
+		{
+			name: "groups",
+			pod:  &v1.PodSecurityContext{FSGroup: Int64Ptr(2000), SupplementalGroups: []int64{4000, 5000}},
+			want: iopodman.Create{Groupadd: &[]string{"2000", "4000", "5000"}},
+		},
+		{
+			name: "capabilities",
+			sc: &v1.SecurityContext{Capabilities: &v1.Capabilities{
+				Add:  []v1.Capability{"NET_ADMIN", "CAP_SYS_TIME"},
+				Drop: []v1.Capability{"all"},
+			}},
+			want: iopodman.Create{
+				CapAdd:  &[]string{"CAP_NET_ADMIN", "CAP_SYS_TIME"},
+				CapDrop: &[]string{"ALL"},
+			},
+		},
+		{
+			name: "privileged and read only",
+			sc:   &v1.SecurityContext{Privileged: &yes, ReadOnlyRootFilesystem: &yes},
+			want: iopodman.Create{Privileged: &yes, Readonly: &yes},
+		},
+		{
+			name: "no privilege escalation",
+			sc:   &v1.SecurityContext{AllowPrivilegeEscalation: &no},
+			want: iopodman.Create{SecurityOpt: &[]string{"no-new-privileges"}},
+		},
+		{
+			name: "container selinux overrides pod",
+			pod:  &v1.PodSecurityContext{SELinuxOptions: &v1.SELinuxOptions{Level: "s0:c1,c2"}},
+			sc:   &v1.SecurityContext{SELinuxOptions: &v1.SELinuxOptions{Type: "spc_t", Level: "s0:c3,c4"}},
+			want: iopodman.Create{SecurityOpt: &[]string{"label=type:spc_t", "label=level:s0:c3,c4"}},
+		},
+		{
+			name: "sysctls",
+			pod:  &v1.PodSecurityContext{Sysctls: []v1.Sysctl{{Name: "net.core.somaxconn", Value: "1024"}}},
+			want: iopodman.Create{Sysctl: &[]string{"net.core.somaxconn=1024"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := v1.Pod{Spec: v1.PodSpec{SecurityContext: tt.pod}}
+			var got iopodman.Create
+			setSecurityContext(pod, v1.Container{Name: "app", SecurityContext: tt.sc}, &got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSecurityContext(t *testing.T) {
+	yes := true
+	unmasked := v1.UnmaskedProcMount
+	tests := []struct {
+		name    string
+		spec    v1.PodSpec
+		wantErr bool
+	}{
+		{
+			name: "none",
+			spec: v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+		},
+		{
+			name: "net sysctl",
+			spec: v1.PodSpec{SecurityContext: &v1.PodSecurityContext{Sysctls: []v1.Sysctl{{Name: "net.ipv4.ip_forward", Value: "1"}}}},
+		},
+		{
+			name: "net sysctl with host network",
+			spec: v1.PodSpec{
+				HostNetwork:     true,
+				SecurityContext: &v1.PodSecurityContext{Sysctls: []v1.Sysctl{{Name: "net.ipv4.ip_forward", Value: "1"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ipc sysctl with host ipc",
+			spec: v1.PodSpec{
+				HostIPC:         true,
+				SecurityContext: &v1.PodSecurityContext{Sysctls: []v1.Sysctl{{Name: "kernel.shmmax", Value: "1"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "node sysctl",
+			spec:    v1.PodSpec{SecurityContext: &v1.PodSecurityContext{Sysctls: []v1.Sysctl{{Name: "vm.swappiness", Value: "1"}}}},
+			wantErr: true,
+		},
+		{
+			name: "unmasked proc mount",
+			spec: v1.PodSpec{InitContainers: []v1.Container{
+				{Name: "init", SecurityContext: &v1.SecurityContext{ProcMount: &unmasked}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "group without user",
+			spec: v1.PodSpec{
+				SecurityContext: &v1.PodSecurityContext{RunAsGroup: Int64Ptr(3000)},
+				Containers:      []v1.Container{{Name: "app"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "non root as root",
+			spec: v1.PodSpec{
+				SecurityContext: &v1.PodSecurityContext{RunAsNonRoot: &yes},
+				Containers: []v1.Container{
+					{Name: "app", SecurityContext: &v1.SecurityContext{RunAsUser: Int64Ptr(0)}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "non root",
+			spec: v1.PodSpec{
+				SecurityContext: &v1.PodSecurityContext{RunAsNonRoot: &yes, RunAsUser: Int64Ptr(1000)},
+				Containers:      []v1.Container{{Name: "app"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSecurityContext(&v1.Pod{Spec: tt.spec})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
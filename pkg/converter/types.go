@@ -26,7 +26,8 @@ type PodmanPod struct {
 	} `json:"Containers"`
 }
 
-type PodmanContainer []struct {
+// PodmanContainer is container inspect data returned by podman
+type PodmanContainer struct {
 	ID      string    `json:"Id"`
 	Created time.Time `json:"Created"`
 	Path    string    `json:"Path"`
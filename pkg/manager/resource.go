@@ -15,9 +15,12 @@
 package manager
 
 import (
+	"context"
+
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 )
@@ -29,19 +32,64 @@ type ResourceManager struct {
 	secretLister    corev1listers.SecretLister
 	configMapLister corev1listers.ConfigMapLister
 	serviceLister   corev1listers.ServiceLister
+	nodeLister      corev1listers.NodeLister
+
+	serviceAccountLister corev1listers.ServiceAccountLister
+
+	// synced reports whether informers backing the listers synced
+	synced []cache.InformerSynced
+	// healthCheck reports whether the API server is reachable
+	healthCheck func() error
 }
 
 // NewResourceManager returns a ResourceManager with the internal maps initialized.
-func NewResourceManager(podLister corev1listers.PodLister, secretLister corev1listers.SecretLister, configMapLister corev1listers.ConfigMapLister, serviceLister corev1listers.ServiceLister) (*ResourceManager, error) {
+// synced reports whether informers backing the listers synced.
+func NewResourceManager(podLister corev1listers.PodLister, secretLister corev1listers.SecretLister, configMapLister corev1listers.ConfigMapLister, serviceLister corev1listers.ServiceLister, serviceAccountLister corev1listers.ServiceAccountLister, nodeLister corev1listers.NodeLister, synced ...cache.InformerSynced) (*ResourceManager, error) {
 	rm := ResourceManager{
-		podLister:       podLister,
-		secretLister:    secretLister,
-		configMapLister: configMapLister,
-		serviceLister:   serviceLister,
+		podLister:            podLister,
+		secretLister:         secretLister,
+		configMapLister:      configMapLister,
+		serviceLister:        serviceLister,
+		serviceAccountLister: serviceAccountLister,
+		nodeLister:           nodeLister,
+		synced:               synced,
 	}
 	return &rm, nil
 }
 
+// HasSynced returns true once the informers backing the listers synced.
+func (rm *ResourceManager) HasSynced() bool {
+	for _, synced := range rm.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// WaitForSync waits until the informers backing the listers synced. It returns false if the context is done first.
+func (rm *ResourceManager) WaitForSync(ctx context.Context) bool {
+	return cache.WaitForCacheSync(ctx.Done(), rm.synced...)
+}
+
+// SetHealthCheck sets the check of the API server backing the listers.
+func (rm *ResourceManager) SetHealthCheck(check func() error) {
+	rm.healthCheck = check
+}
+
+// CheckHealth returns an error if the API server is unreachable, so the listers may serve stale objects.
+func (rm *ResourceManager) CheckHealth() error {
+	if rm.healthCheck == nil {
+		return nil
+	}
+	return rm.healthCheck()
+}
+
+// GetNode retrieves the specified node from the cache.
+func (rm *ResourceManager) GetNode(name string) (*v1.Node, error) {
+	return rm.nodeLister.Get(name)
+}
+
 // GetPods returns a list of all known pods assigned to this virtual node.
 func (rm *ResourceManager) GetPods() []*v1.Pod {
 	l, err := rm.podLister.List(labels.Everything())
@@ -62,6 +110,11 @@ func (rm *ResourceManager) GetSecret(name, namespace string) (*v1.Secret, error)
 	return rm.secretLister.Secrets(namespace).Get(name)
 }
 
+// GetServiceAccount retrieves the specified service account from the cache.
+func (rm *ResourceManager) GetServiceAccount(name, namespace string) (*v1.ServiceAccount, error) {
+	return rm.serviceAccountLister.ServiceAccounts(namespace).Get(name)
+}
+
 // ListServices retrieves the list of services from Kubernetes.
 func (rm *ResourceManager) ListServices() ([]*v1.Service, error) {
 	return rm.serviceLister.List(labels.Everything())
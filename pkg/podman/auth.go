@@ -0,0 +1,271 @@
+package podman
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// defaultRegistry is registry of images without registry host
+	defaultRegistry = "docker.io"
+	// pullAuthBackup is suffix of the auth file backup kept during pull and
+	// pullAuthCreated is suffix of the marker of auth file created for pull
+	pullAuthBackup  = ".vk-backup"
+	pullAuthCreated = ".vk-created"
+)
+
+// dockerConfigEntry is registry credentials in docker config
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// dockerConfig is registry credentials by registry. It is format of the
+// kubernetes.io/dockercfg secrets
+type dockerConfig map[string]dockerConfigEntry
+
+// dockerConfigJSON is format of the kubernetes.io/dockerconfigjson secrets
+// and of the auth file read by podman
+type dockerConfigJSON struct {
+	Auths dockerConfig `json:"auths"`
+}
+
+// pullCredentials returns registry credentials from the image pull secrets
+// of the pod and of its service account. Missing secrets are skipped, the
+// pull might still succeed with node credentials
+func (p podman) pullCredentials(pod *corev1.Pod) dockerConfig {
+	if p.resourceManager == nil {
+		return nil
+	}
+
+	refs := pod.Spec.ImagePullSecrets
+	name := pod.Spec.ServiceAccountName
+	if name == "" {
+		name = "default"
+	}
+	sa, err := p.resourceManager.GetServiceAccount(name, pod.Namespace)
+	if err != nil {
+		p.log.Debug("unable to get service account ", pod.Namespace, "/", name, ": ", err.Error())
+	} else {
+		refs = append(refs, sa.ImagePullSecrets...)
+	}
+
+	auths := dockerConfig{}
+	for _, ref := range refs {
+		secret, err := p.resourceManager.GetSecret(ref.Name, pod.Namespace)
+		if err != nil {
+			p.log.Info("unable to get pull secret ", pod.Namespace, "/", ref.Name, ", the image pull may not succeed: ", err.Error())
+			continue
+		}
+		config, err := parseDockerConfig(secret)
+		if err != nil {
+			p.log.Info("invalid pull secret ", pod.Namespace, "/", ref.Name, ": ", err.Error())
+			continue
+		}
+		// first secret of the registry wins
+		for registry, entry := range config {
+			if _, ok := auths[registry]; !ok {
+				auths[registry] = entry
+			}
+		}
+	}
+	return auths
+}
+
+// parseDockerConfig returns registry credentials from the docker config
+// secret
+func parseDockerConfig(secret *corev1.Secret) (dockerConfig, error) {
+	switch secret.Type {
+	case corev1.SecretTypeDockerConfigJson:
+		var config dockerConfigJSON
+		err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config)
+		return config.Auths, err
+	case corev1.SecretTypeDockercfg:
+		var config dockerConfig
+		err := json.Unmarshal(secret.Data[corev1.DockerConfigKey], &config)
+		return config, err
+	}
+	return nil, fmt.Errorf("unsupported secret type %s", secret.Type)
+}
+
+// readAuthFile returns registry credentials from the auth file. Missing
+// file has no credentials
+func readAuthFile(path string) (dockerConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var config dockerConfigJSON
+	err = json.Unmarshal(data, &config)
+	return config.Auths, err
+}
+
+// writePullAuth hands credentials of the pod for the image registry to
+// podman by writing them into the auth file read by podman on pull. Podman
+// varlink pull has no credentials parameter, so the file is shared by all
+// pulls on the host until it is restored. Credentials already present in
+// the file are kept for other registries. Original file is backed up
+// first, so it is restored by restorePullAuth even if the provider is
+// killed during the pull. Returned function restores the file. Caller must
+// hold the pull lock, so pulls do not race for the file
+func (p podman) writePullAuth(image string, auths dockerConfig) (func(), error) {
+	noop := func() {}
+	nodeAuths, err := readAuthFile(p.authFile)
+	if err != nil {
+		return noop, err
+	}
+	registry := imageRegistry(image)
+	imageAuths := dockerConfig{}
+	for _, config := range []dockerConfig{nodeAuths, auths} {
+		if entry, ok := registryEntry(config, registry); ok {
+			imageAuths[registry] = normalizeEntry(entry)
+		}
+	}
+	if len(imageAuths) == 0 {
+		return noop, nil
+	}
+
+	original, err := ioutil.ReadFile(p.pullAuthFile)
+	if err != nil && !os.IsNotExist(err) {
+		return noop, err
+	}
+	if err == nil {
+		err = writeFileAtomic(p.pullAuthFile+pullAuthBackup, original)
+	} else {
+		err = writeFileAtomic(p.pullAuthFile+pullAuthCreated, nil)
+	}
+	if err != nil {
+		return noop, err
+	}
+
+	merged := dockerConfig{}
+	var config dockerConfigJSON
+	if err := json.Unmarshal(original, &config); err == nil {
+		for r, entry := range config.Auths {
+			merged[r] = entry
+		}
+	}
+	for r, entry := range imageAuths {
+		merged[r] = entry
+	}
+	data, err := json.Marshal(dockerConfigJSON{Auths: merged})
+	if err == nil {
+		err = writeFileAtomic(p.pullAuthFile, data)
+	}
+	restore := func() {
+		if err := p.restorePullAuth(); err != nil {
+			p.log.Error("error restoring auth file", "err", err.Error())
+		}
+	}
+	if err != nil {
+		restore()
+		return noop, err
+	}
+	return restore, nil
+}
+
+// restorePullAuth restores auth file changed by writePullAuth from its
+// backup. It is called on start to clean up after pull interrupted by crash
+func (p podman) restorePullAuth() error {
+	err := os.Rename(p.pullAuthFile+pullAuthBackup, p.pullAuthFile)
+	if !os.IsNotExist(err) {
+		return err
+	}
+	_, err = os.Stat(p.pullAuthFile + pullAuthCreated)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	err = os.Remove(p.pullAuthFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(p.pullAuthFile + pullAuthCreated)
+}
+
+// imageRegistry returns registry host of the image, docker.io if image
+// name has no registry
+func imageRegistry(image string) string {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return defaultRegistry
+	}
+	host := image[:i]
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return defaultRegistry
+	}
+	return normalizeRegistry(host)
+}
+
+// registryEntry returns credentials of the registry. Keys of docker config
+// might be URLs, e.g. https://index.docker.io/v1/
+func registryEntry(config dockerConfig, registry string) (dockerConfigEntry, bool) {
+	for key, entry := range config {
+		if normalizeRegistry(key) == registry {
+			return entry, true
+		}
+	}
+	return dockerConfigEntry{}, false
+}
+
+// normalizeRegistry strips scheme and path of the registry and maps docker
+// hub aliases to docker.io
+func normalizeRegistry(registry string) string {
+	if i := strings.Index(registry, "://"); i >= 0 {
+		registry = registry[i+3:]
+	}
+	registry = strings.SplitN(registry, "/", 2)[0]
+	switch registry {
+	case "index.docker.io", "registry-1.docker.io":
+		return defaultRegistry
+	}
+	return registry
+}
+
+// normalizeEntry sets auth field of the entry, which is the only field used
+// by podman
+func normalizeEntry(entry dockerConfigEntry) dockerConfigEntry {
+	if entry.Auth == "" && entry.Username != "" {
+		entry.Auth = base64.StdEncoding.EncodeToString([]byte(entry.Username + ":" + entry.Password))
+	}
+	return entry
+}
+
+// writeFileAtomic replaces file readable only by the owner
+func writeFileAtomic(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".auth")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
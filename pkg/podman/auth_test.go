@@ -0,0 +1,231 @@
+package podman
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestImageRegistry(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"nginx", "docker.io"},
+		{"library/nginx:1.17", "docker.io"},
+		{"docker.io/library/nginx", "docker.io"},
+		{"index.docker.io/library/nginx", "docker.io"},
+		{"quay.io/podman/stable", "quay.io"},
+		{"registry:5000/app", "registry:5000"},
+		{"localhost/app", "localhost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := imageRegistry(tt.image); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryEntry(t *testing.T) {
+	config := dockerConfig{
+		"https://index.docker.io/v1/": {Auth: "hub"},
+		"registry:5000":               {Auth: "private"},
+		"https://quay.io/v2/":         {Auth: "quay"},
+	}
+	tests := []struct {
+		registry string
+		want     string
+		found    bool
+	}{
+		{"docker.io", "hub", true},
+		{"registry:5000", "private", true},
+		{"quay.io", "quay", true},
+		{"gcr.io", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			entry, found := registryEntry(config, tt.registry)
+			if found != tt.found || entry.Auth != tt.want {
+				t.Errorf("got %q %v, want %q %v", entry.Auth, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestParseDockerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  *corev1.Secret
+		want    dockerConfig
+		wantErr bool
+	}{
+		{
+			name: "dockerconfigjson",
+			secret: &corev1.Secret{
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{"quay.io":{"username":"u","password":"p"}}}`)},
+			},
+			want: dockerConfig{"quay.io": {Username: "u", Password: "p"}},
+		},
+		{
+			name: "dockercfg",
+			secret: &corev1.Secret{
+				Type: corev1.SecretTypeDockercfg,
+				Data: map[string][]byte{corev1.DockerConfigKey: []byte(`{"quay.io":{"auth":"dTpw"}}`)},
+			},
+			want: dockerConfig{"quay.io": {Auth: "dTpw"}},
+		},
+		{
+			name:    "opaque",
+			secret:  &corev1.Secret{Type: corev1.SecretTypeOpaque},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDockerConfig(tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWritePullAuth(t *testing.T) {
+	original := `{"auths":{"gcr.io":{"auth":"node"}}}`
+	tests := []struct {
+		name     string
+		original string
+		image    string
+		auths    dockerConfig
+		want     dockerConfig
+	}{
+		{
+			name:     "merged with existing file",
+			original: original,
+			image:    "quay.io/app",
+			auths:    dockerConfig{"quay.io": {Username: "u", Password: "p"}, "docker.io": {Auth: "other"}},
+			want:     dockerConfig{"gcr.io": {Auth: "node"}, "quay.io": {Username: "u", Password: "p", Auth: "dTpw"}},
+		},
+		{
+			name:  "created",
+			image: "nginx",
+			auths: dockerConfig{"https://index.docker.io/v1/": {Auth: "hub"}},
+			want:  dockerConfig{"docker.io": {Auth: "hub"}},
+		},
+		{
+			name:     "no credentials of the registry",
+			original: original,
+			image:    "nginx",
+			auths:    dockerConfig{"quay.io": {Auth: "quay"}},
+			want:     dockerConfig{"gcr.io": {Auth: "node"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "auth")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			p := podman{pullAuthFile: filepath.Join(dir, "auth.json"), log: zap.NewNop().Sugar()}
+			if tt.original != "" {
+				if err := ioutil.WriteFile(p.pullAuthFile, []byte(tt.original), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			restore, err := p.writePullAuth(tt.image, tt.auths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := readAuthFile(p.pullAuthFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+
+			restore()
+			assertRestored(t, p.pullAuthFile, tt.original)
+		})
+	}
+}
+
+func TestRestorePullAuth(t *testing.T) {
+	original := `{"auths":{"gcr.io":{"auth":"node"}}}`
+	tests := []struct {
+		name     string
+		original string
+	}{
+		{"existing file", original},
+		{"created file", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "auth")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			p := podman{pullAuthFile: filepath.Join(dir, "auth.json"), log: zap.NewNop().Sugar()}
+			if tt.original != "" {
+				if err := ioutil.WriteFile(p.pullAuthFile, []byte(tt.original), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// provider killed during pull leaves credentials in the file
+			_, err = p.writePullAuth("quay.io/app", dockerConfig{"quay.io": {Auth: "secret"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.restorePullAuth(); err != nil {
+				t.Fatal(err)
+			}
+			assertRestored(t, p.pullAuthFile, tt.original)
+
+			// nothing to restore
+			if err := p.restorePullAuth(); err != nil {
+				t.Fatal(err)
+			}
+			assertRestored(t, p.pullAuthFile, tt.original)
+		})
+	}
+}
+
+// assertRestored checks that auth file has its original content or does
+// not exist, and no backup is left
+func assertRestored(t *testing.T, path, original string) {
+	data, err := ioutil.ReadFile(path)
+	if original == "" {
+		if !os.IsNotExist(err) {
+			t.Errorf("got auth file %q, want removed", data)
+		}
+	} else if string(data) != original {
+		t.Errorf("got auth file %q, want %q", data, original)
+	}
+	for _, suffix := range []string{pullAuthBackup, pullAuthCreated} {
+		if _, err := os.Stat(path + suffix); !os.IsNotExist(err) {
+			t.Errorf("got %s left", path+suffix)
+		}
+	}
+	var config dockerConfigJSON
+	if original != "" {
+		if err := json.Unmarshal(data, &config); err != nil {
+			t.Errorf("got invalid auth file: %v", err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package podman
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+)
+
+const (
+	// hostsFile and resolvConfFile are names of the pod network files in
+	// the pod directory
+	hostsFile      = "etc-hosts"
+	resolvConfFile = "resolv.conf"
+)
+
+// dnsConfig returns resolver configuration of the pod containers
+func (p podman) dnsConfig(pod *corev1.Pod) (converter.DNSConfig, error) {
+	data, err := ioutil.ReadFile(p.resolvConf)
+	if err != nil && !os.IsNotExist(err) {
+		return converter.DNSConfig{}, err
+	}
+	node := converter.ParseResolvConf(data)
+	return converter.GetDNSConfig(pod, p.clusterDomain, p.clusterDNS, node), nil
+}
+
+// setupNetworkFiles writes resolv.conf and hosts file of the pod. Podman
+// gives containers sharing the network namespace files of the infra
+// container and ignores their dns options, so pod files are mounted
+// instead, the same way kubelet manages /etc/hosts
+func (p podman) setupNetworkFiles(ctx context.Context, pod *corev1.Pod, key string) error {
+	if pod.Spec.HostNetwork {
+		return nil
+	}
+	config, err := p.dnsConfig(pod)
+	if err != nil {
+		return err
+	}
+	podIP, err := p.podIP(ctx, key)
+	if err != nil {
+		return err
+	}
+	dir, err := p.podDir(pod)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, resolvConfFile), config.ResolvConf(), 0644)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, hostsFile), converter.HostsFile(pod, podIP, p.clusterDomain), 0644)
+}
+
+// syncHostsFile rewrites hosts file of the pod when the pod IP changed,
+// e.g. after the infra container was restarted. File is written in place,
+// as containers bind mount it
+func (p podman) syncHostsFile(pod *corev1.Pod, containers []converter.PodmanContainer) error {
+	if pod.Spec.HostNetwork {
+		return nil
+	}
+	podIP := converter.GetPodIP(containers)
+	if podIP == "" {
+		return nil
+	}
+	dir, err := p.podDir(pod)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, hostsFile)
+	data := converter.HostsFile(pod, podIP, p.clusterDomain)
+	current, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// setNetwork sets resolver, hosts and hostname of the container
+func (p podman) setNetwork(pod *corev1.Pod, create *iopodman.Create) error {
+	if pod.Spec.HostNetwork {
+		config, err := p.dnsConfig(pod)
+		if err != nil {
+			return err
+		}
+		converter.SetHostNetworkDNS(pod, config, create)
+		return nil
+	}
+
+	dir, err := p.podDir(pod)
+	if err != nil {
+		return err
+	}
+	hostname, _ := converter.GetHostname(pod, p.clusterDomain)
+	create.Hostname = &hostname
+	volumes := []string{
+		fmt.Sprintf("%s:/etc/hosts", filepath.Join(dir, hostsFile)),
+		fmt.Sprintf("%s:/etc/resolv.conf", filepath.Join(dir, resolvConfFile)),
+	}
+	if create.Volume != nil {
+		volumes = append(*create.Volume, volumes...)
+	}
+	create.Volume = &volumes
+	return nil
+}
+
+// podIP returns IP address of the pod. Infra container is started to get
+// the address, as containers are created before the pod is started
+func (p podman) podIP(ctx context.Context, key string) (string, error) {
+	containers, err := p.podContainers(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if podIP := converter.GetPodIP(containers); podIP != "" {
+		return podIP, nil
+	}
+
+	err = p.startInfra(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	containers, err = p.podContainers(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return converter.GetPodIP(containers), nil
+}
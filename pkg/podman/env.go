@@ -0,0 +1,236 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/v1/resource"
+	"k8s.io/kubernetes/pkg/fieldpath"
+	"k8s.io/kubernetes/third_party/forked/golang/expansion"
+)
+
+// envResolver resolves environment of the pod containers. ConfigMaps,
+// secrets and pod IP are fetched once per pod
+type envResolver struct {
+	p          podman
+	ctx        context.Context
+	pod        *corev1.Pod
+	key        string
+	podIP      *string
+	configMaps map[string]*corev1.ConfigMap
+	secrets    map[string]*corev1.Secret
+}
+
+func (p podman) newEnvResolver(ctx context.Context, pod *corev1.Pod, key string) *envResolver {
+	return &envResolver{
+		p:          p,
+		ctx:        ctx,
+		pod:        pod,
+		key:        key,
+		configMaps: map[string]*corev1.ConfigMap{},
+		secrets:    map[string]*corev1.Secret{},
+	}
+}
+
+// environment returns NAME=value environment of the container, the same
+// way kubelet does. envFrom is expanded first and env overrides it, $(VAR)
+// references are expanded in order of the declaration
+func (r *envResolver) environment(container corev1.Container) ([]string, error) {
+	tmpEnv := map[string]string{}
+	for _, envFrom := range container.EnvFrom {
+		switch {
+		case envFrom.ConfigMapRef != nil:
+			ref := envFrom.ConfigMapRef
+			configMap, err := r.configMap(ref.Name, ref.Optional)
+			if err != nil {
+				return nil, err
+			}
+			if configMap == nil {
+				continue
+			}
+			for k, v := range configMap.Data {
+				tmpEnv[envFrom.Prefix+k] = v
+			}
+		case envFrom.SecretRef != nil:
+			ref := envFrom.SecretRef
+			secret, err := r.secret(ref.Name, ref.Optional)
+			if err != nil {
+				return nil, err
+			}
+			if secret == nil {
+				continue
+			}
+			for k, v := range secret.Data {
+				tmpEnv[envFrom.Prefix+k] = string(v)
+			}
+		}
+	}
+
+	mapping := expansion.MappingFuncFor(tmpEnv)
+	for _, envVar := range container.Env {
+		value := envVar.Value
+		if value != "" {
+			value = expansion.Expand(value, mapping)
+		} else if envVar.ValueFrom != nil {
+			var ok bool
+			var err error
+			value, ok, err = r.valueFrom(container, envVar.ValueFrom)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		tmpEnv[envVar.Name] = value
+	}
+
+	env := make([]string, 0, len(tmpEnv))
+	for k, v := range tmpEnv {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// expandCommand returns container with $(VAR) references in command and
+// args expanded over its resolved NAME=value environment, same as kubelet
+func expandCommand(container corev1.Container, env []string) corev1.Container {
+	vars := map[string]string{}
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		vars[kv[0]] = kv[1]
+	}
+	mapping := expansion.MappingFuncFor(vars)
+	expand := func(values []string) []string {
+		if len(values) == 0 {
+			return values
+		}
+		expanded := make([]string, 0, len(values))
+		for _, v := range values {
+			expanded = append(expanded, expansion.Expand(v, mapping))
+		}
+		return expanded
+	}
+	container.Command = expand(container.Command)
+	container.Args = expand(container.Args)
+	return container
+}
+
+// valueFrom resolves source of the environment variable. It returns false
+// if optional source does not exist
+func (r *envResolver) valueFrom(container corev1.Container, from *corev1.EnvVarSource) (string, bool, error) {
+	switch {
+	case from.FieldRef != nil:
+		value, err := r.fieldRef(from.FieldRef)
+		return value, err == nil, err
+	case from.ResourceFieldRef != nil:
+		value, err := resource.ExtractResourceValueByContainerNameAndNodeAllocatable(
+			from.ResourceFieldRef, r.pod, container.Name, r.p.allocatable)
+		return value, err == nil, err
+	case from.ConfigMapKeyRef != nil:
+		ref := from.ConfigMapKeyRef
+		configMap, err := r.configMap(ref.Name, ref.Optional)
+		if err != nil || configMap == nil {
+			return "", false, err
+		}
+		value, ok := configMap.Data[ref.Key]
+		if !ok && !isOptional(ref.Optional) {
+			return "", false, fmt.Errorf("couldn't find key %s in ConfigMap %s/%s", ref.Key, r.pod.Namespace, ref.Name)
+		}
+		return value, ok, nil
+	case from.SecretKeyRef != nil:
+		ref := from.SecretKeyRef
+		secret, err := r.secret(ref.Name, ref.Optional)
+		if err != nil || secret == nil {
+			return "", false, err
+		}
+		value, ok := secret.Data[ref.Key]
+		if !ok && !isOptional(ref.Optional) {
+			return "", false, fmt.Errorf("couldn't find key %s in Secret %s/%s", ref.Key, r.pod.Namespace, ref.Name)
+		}
+		return string(value), ok, nil
+	}
+	return "", true, nil
+}
+
+// fieldRef resolves downward API field of the pod
+func (r *envResolver) fieldRef(ref *corev1.ObjectFieldSelector) (string, error) {
+	switch ref.FieldPath {
+	case "spec.nodeName":
+		return r.pod.Spec.NodeName, nil
+	case "spec.serviceAccountName":
+		return r.pod.Spec.ServiceAccountName, nil
+	case "status.hostIP":
+		return r.p.hostIP, nil
+	case "status.podIP":
+		return r.getPodIP()
+	}
+	return fieldpath.ExtractFieldPathAsString(r.pod, ref.FieldPath)
+}
+
+// getPodIP returns IP address of the pod
+func (r *envResolver) getPodIP() (string, error) {
+	if r.podIP != nil {
+		return *r.podIP, nil
+	}
+	if r.pod.Spec.HostNetwork {
+		r.podIP = &r.p.hostIP
+		return r.p.hostIP, nil
+	}
+
+	podIP, err := r.p.podIP(r.ctx, r.key)
+	if err != nil {
+		return "", err
+	}
+	r.podIP = &podIP
+	return podIP, nil
+}
+
+// configMap returns configMap from the pod namespace. Nil is returned if
+// optional configMap does not exist
+func (r *envResolver) configMap(name string, optional *bool) (*corev1.ConfigMap, error) {
+	if configMap, ok := r.configMaps[name]; ok {
+		return configMap, nil
+	}
+	if r.p.resourceManager == nil {
+		return nil, fmt.Errorf("couldn't get configMap %s/%s, no resource manager defined", r.pod.Namespace, name)
+	}
+	configMap, err := r.p.resourceManager.GetConfigMap(name, r.pod.Namespace)
+	if err != nil {
+		if k8serrors.IsNotFound(err) && isOptional(optional) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	r.configMaps[name] = configMap
+	return configMap, nil
+}
+
+// secret returns secret from the pod namespace. Nil is returned if
+// optional secret does not exist
+func (r *envResolver) secret(name string, optional *bool) (*corev1.Secret, error) {
+	if secret, ok := r.secrets[name]; ok {
+		return secret, nil
+	}
+	if r.p.resourceManager == nil {
+		return nil, fmt.Errorf("couldn't get secret %s/%s, no resource manager defined", r.pod.Namespace, name)
+	}
+	secret, err := r.p.resourceManager.GetSecret(name, r.pod.Namespace)
+	if err != nil {
+		if k8serrors.IsNotFound(err) && isOptional(optional) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	r.secrets[name] = secret
+	return secret, nil
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
@@ -0,0 +1,122 @@
+package podman
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEnvironment(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "web"},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:           "edge",
+			ServiceAccountName: "builder",
+			HostNetwork:        true,
+		},
+	}
+	tests := []struct {
+		name    string
+		env     []corev1.EnvVar
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "values",
+			env:  []corev1.EnvVar{{Name: "B", Value: "2"}, {Name: "A", Value: "1"}},
+			want: []string{"A=1", "B=2"},
+		},
+		{
+			name: "expansion in declaration order",
+			env: []corev1.EnvVar{
+				{Name: "HOST", Value: "db"},
+				{Name: "URL", Value: "http://$(HOST):$(PORT)"},
+				{Name: "PORT", Value: "80"},
+				{Name: "ESCAPED", Value: "$$(HOST)"},
+			},
+			want: []string{"ESCAPED=$(HOST)", "HOST=db", "PORT=80", "URL=http://db:$(PORT)"},
+		},
+		{
+			name: "downward API",
+			env: []corev1.EnvVar{
+				{Name: "NODE", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}}},
+				{Name: "SA", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.serviceAccountName"}}},
+				{Name: "NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+				{Name: "APP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.labels['app']"}}},
+				{Name: "HOST_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.hostIP"}}},
+				{Name: "POD_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+			},
+			want: []string{"APP=web", "HOST_IP=192.168.1.2", "NAME=web", "NODE=edge", "POD_IP=192.168.1.2", "SA=builder"},
+		},
+		{
+			name: "configMap without resource manager",
+			env: []corev1.EnvVar{{Name: "A", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "config"},
+				Key:                  "a",
+			}}}},
+			wantErr: true,
+		},
+	}
+	p := podman{hostIP: "192.168.1.2"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := corev1.Container{Name: "app", Env: tt.env}
+			got, err := p.newEnvResolver(context.Background(), pod, "default-web").environment(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	env := []string{"GREETING=hello world", "EMPTY=", "EQ=a=b"}
+	tests := []struct {
+		name        string
+		command     []string
+		args        []string
+		wantCommand []string
+		wantArgs    []string
+	}{
+		{
+			name:        "references",
+			command:     []string{"/bin/echo", "$(GREETING)"},
+			args:        []string{"$(EQ)", "[$(EMPTY)]"},
+			wantCommand: []string{"/bin/echo", "hello world"},
+			wantArgs:    []string{"a=b", "[]"},
+		},
+		{
+			name:     "unknown and escaped",
+			args:     []string{"$(MISSING)", "$$(GREETING)", "$GREETING"},
+			wantArgs: []string{"$(MISSING)", "$(GREETING)", "$GREETING"},
+		},
+		{
+			name: "empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := corev1.Container{Command: tt.command, Args: tt.args}
+			got := expandCommand(c, env)
+			if !reflect.DeepEqual(got.Command, tt.wantCommand) {
+				t.Errorf("got command %q, want %q", got.Command, tt.wantCommand)
+			}
+			if !reflect.DeepEqual(got.Args, tt.wantArgs) {
+				t.Errorf("got args %q, want %q", got.Args, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(c.Args, tt.args) {
+				t.Errorf("container args changed to %q", c.Args)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package podman
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/varlink/go/varlink"
+
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+	"github.com/virtual-kubelet/podman/pkg/util/errors"
+)
+
+const (
+	// changesBuffer is number of pod changes buffered for the watcher
+	changesBuffer = 100
+	// podman event types
+	containerEvent = "container"
+	podEvent       = "pod"
+)
+
+var (
+	// containerStatusEvents are container events changing status of its pod
+	containerStatusEvents = map[string]bool{
+		"start":         true,
+		"died":          true,
+		"oom":           true,
+		"health_status": true,
+		"remove":        true,
+	}
+	// podStatusEvents are pod events changing the pod status
+	podStatusEvents = map[string]bool{
+		"start":  true,
+		"stop":   true,
+		"kill":   true,
+		"remove": true,
+	}
+)
+
+// changeNotifier passes keys of the pods, which status changed inside the
+// provider, to the watcher. Changes are dropped when buffer is full, the
+// periodic resync refreshes such pods later
+type changeNotifier struct {
+	sync.Mutex
+	changes chan string
+	pending map[string]time.Time
+}
+
+func newChangeNotifier() *changeNotifier {
+	return &changeNotifier{
+		changes: make(chan string, changesBuffer),
+		pending: make(map[string]time.Time),
+	}
+}
+
+// notify reports change of the pod
+func (n *changeNotifier) notify(key string) {
+	select {
+	case n.changes <- key:
+	default:
+	}
+}
+
+// notifyAt reports change of the pod at the given time, e.g. when back-off
+// of its container expires. Later time is not scheduled while earlier one
+// is pending
+func (n *changeNotifier) notifyAt(key string, at time.Time) {
+	n.Lock()
+	defer n.Unlock()
+	if pending, ok := n.pending[key]; ok && !pending.After(at) {
+		return
+	}
+	n.pending[key] = at
+	time.AfterFunc(time.Until(at), func() {
+		n.Lock()
+		if n.pending[key].Equal(at) {
+			delete(n.pending, key)
+		}
+		n.Unlock()
+		n.notify(key)
+	})
+}
+
+// Watch streams keys of the pods, which status changed. Podman events are
+// read using dedicated connection and merged with changes detected by the
+// provider, like expired back-off or changed readiness. Channel is closed
+// when the event stream ends or context is cancelled. Only one watcher is
+// supported
+func (p podman) Watch(ctx context.Context) (<-chan string, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	receive, err := iopodman.GetEvents().Send(ctx, conn, varlink.More, nil, "", "")
+	if err != nil {
+		cancel()
+		conn.Close()
+		return nil, errors.VKError(err)
+	}
+	pods, err := p.containerPods(ctx)
+	if err != nil {
+		cancel()
+		conn.Close()
+		return nil, err
+	}
+
+	events := make(chan iopodman.Event)
+	go func() {
+		defer close(events)
+		for {
+			event, flags, err := receive(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					p.log.Error("error getEvents", "err", err.Error())
+				}
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+			if flags&varlink.Continues == 0 {
+				return
+			}
+		}
+	}()
+
+	keys := make(chan string, changesBuffer)
+	go func() {
+		defer close(keys)
+		defer conn.Close()
+		defer cancel()
+		for {
+			var key string
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				key = p.eventPod(ctx, event, pods)
+			case key = <-p.changes.changes:
+			case <-ctx.Done():
+				return
+			}
+			if key == "" {
+				continue
+			}
+			select {
+			case keys <- key:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return keys, nil
+}
+
+// eventPod returns key of the pod, which status is changed by the event.
+// Empty key is returned for other events. Pods maps container IDs to pod
+// keys, it is refreshed when event of unknown container arrives
+func (p podman) eventPod(ctx context.Context, event iopodman.Event, pods map[string]string) string {
+	switch event.Type {
+	case podEvent:
+		if podStatusEvents[event.Status] {
+			return event.Name
+		}
+	case containerEvent:
+		if !containerStatusEvents[event.Status] {
+			return ""
+		}
+		key, ok := pods[event.Id]
+		if !ok {
+			refreshed, err := p.containerPods(ctx)
+			if err != nil {
+				p.log.Error("error listing pods", "err", err.Error())
+				return ""
+			}
+			for id, k := range refreshed {
+				pods[id] = k
+			}
+			// containers outside of pods are remembered too
+			key = refreshed[event.Id]
+			pods[event.Id] = key
+		}
+		if event.Status == "remove" {
+			delete(pods, event.Id)
+		}
+		return key
+	}
+	return ""
+}
+
+// containerPods returns pod keys by ID of their containers
+func (p podman) containerPods(ctx context.Context) (map[string]string, error) {
+	p.c.Lock()
+	list, err := iopodman.ListPods().Call(ctx, &p.c.Connection)
+	p.c.Unlock()
+	if err != nil {
+		return nil, errors.VKError(err)
+	}
+	pods := map[string]string{}
+	for _, pod := range list {
+		for _, c := range pod.Containersinfo {
+			pods[c.Id] = pod.Name
+		}
+	}
+	return pods, nil
+}
@@ -0,0 +1,75 @@
+package podman
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	utilexec "k8s.io/utils/exec"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+	"github.com/virtual-kubelet/podman/pkg/util/errors"
+)
+
+// Exec executes command in the container and copies data between attach
+// streams and the command stdio. Non zero exit code is returned as
+// exec.CodeExitError, so it is reported back to the kubectl
+func (p podman) Exec(ctx context.Context, namespace, podName, containerName string, cmd []string, attach api.AttachIO) error {
+	key, err := converter.BuildKeyFromNames(namespace, podName)
+	if err != nil {
+		return err
+	}
+
+	code, err := p.exec(ctx, converter.BuildContainerName(key, containerName), cmd, attach)
+	if err != nil {
+		return err
+	}
+	return exitError(code)
+}
+
+// exec executes command in the container and returns its exit code
+func (p podman) exec(ctx context.Context, name string, cmd []string, attach api.AttachIO) (int, error) {
+	in := struct {
+		Opts iopodman.ExecOpts `json:"opts"`
+	}{
+		Opts: iopodman.ExecOpts{
+			Name: name,
+			Tty:  attach.TTY(),
+			Cmd:  cmd,
+		},
+	}
+	s, err := p.upgrade(ctx, "io.podman.ExecContainer", in)
+	if err != nil {
+		p.log.Error("error execContainer", "err", err.Error())
+		return 0, errors.VKError(err)
+	}
+	defer s.Close()
+
+	data, err := s.run(ctx, attach)
+	if err != nil {
+		return 0, err
+	}
+	return exitCode(data), nil
+}
+
+// exitCode decodes exit code of the exec quit frame, which is big-endian
+// encoded in its first four bytes
+func exitCode(data []byte) int {
+	if len(data) < 4 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(data[:4]))
+}
+
+// exitError returns error carrying non zero exit code of the process
+func exitError(code int) error {
+	if code == 0 {
+		return nil
+	}
+	return utilexec.CodeExitError{
+		Err:  fmt.Errorf("command terminated with non-zero exit code %d", code),
+		Code: code,
+	}
+}
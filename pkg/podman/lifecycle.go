@@ -0,0 +1,328 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+	"github.com/virtual-kubelet/podman/pkg/util/errors"
+)
+
+const (
+	// minGracePeriod is time containers always get to stop after preStop
+	// hooks, same as used by kubelet
+	minGracePeriod = 2 * time.Second
+	// terminatingReason is pod status reason reported while the pod
+	// containers are stopped
+	terminatingReason = "Terminating"
+	// postStartHookError is reason of the container killed by failed
+	// postStart hook and failedPostStartHook is reason of its event, same as
+	// reported by kubelet
+	postStartHookError  = "PostStartHookError"
+	failedPostStartHook = "FailedPostStartHook"
+)
+
+// hookTracker keeps running and failed postStart hooks of containers
+// grouped by pod key
+type hookTracker struct {
+	sync.Mutex
+	pods    map[string]map[string]string
+	running map[string]map[string]bool
+}
+
+func newHookTracker() *hookTracker {
+	return &hookTracker{
+		pods:    make(map[string]map[string]string),
+		running: make(map[string]map[string]bool),
+	}
+}
+
+// start records running hook of the container
+func (t *hookTracker) start(key, container string) {
+	t.Lock()
+	defer t.Unlock()
+	if t.running[key] == nil {
+		t.running[key] = make(map[string]bool)
+	}
+	t.running[key][container] = true
+}
+
+// done records finished hook of the container
+func (t *hookTracker) done(key, container string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.running[key], container)
+	if len(t.running[key]) == 0 {
+		delete(t.running, key)
+	}
+}
+
+// isRunning returns true while hook of the container runs
+func (t *hookTracker) isRunning(key, container string) bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.running[key][container]
+}
+
+// failed records failure message of the container hook
+func (t *hookTracker) failed(key, container, message string) {
+	t.Lock()
+	defer t.Unlock()
+	if t.pods[key] == nil {
+		t.pods[key] = make(map[string]string)
+	}
+	t.pods[key][container] = message
+}
+
+// clear forgets failed hook of the container
+func (t *hookTracker) clear(key, container string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.pods[key], container)
+}
+
+// remove forgets all containers of the pod
+func (t *hookTracker) remove(key string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.pods, key)
+	delete(t.running, key)
+}
+
+// message returns failure message of the container hook or empty string
+func (t *hookTracker) message(key, container string) string {
+	t.Lock()
+	defer t.Unlock()
+	return t.pods[key][container]
+}
+
+// terminationTracker keeps keys of pods being terminated
+type terminationTracker struct {
+	sync.Mutex
+	pods map[string]bool
+}
+
+func newTerminationTracker() *terminationTracker {
+	return &terminationTracker{
+		pods: make(map[string]bool),
+	}
+}
+
+func (t *terminationTracker) add(key string) {
+	t.Lock()
+	defer t.Unlock()
+	t.pods[key] = true
+}
+
+func (t *terminationTracker) remove(key string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.pods, key)
+}
+
+func (t *terminationTracker) has(key string) bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.pods[key]
+}
+
+// gracePeriod returns time the pod containers have to terminate
+func gracePeriod(pod *corev1.Pod) time.Duration {
+	seconds := int64(corev1.DefaultTerminationGracePeriodSeconds)
+	if pod.DeletionGracePeriodSeconds != nil {
+		seconds = *pod.DeletionGracePeriodSeconds
+	} else if pod.Spec.TerminationGracePeriodSeconds != nil {
+		seconds = *pod.Spec.TerminationGracePeriodSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// terminate runs preStop hooks of running containers and then stops the
+// pod. Containers get their stop signal and are killed when the rest of the
+// grace period expires
+func (p podman) terminate(ctx context.Context, pod *corev1.Pod, key string) error {
+	deadline := time.Now().Add(gracePeriod(pod))
+	containers, err := p.podContainers(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	hookCtx, cancel := context.WithDeadline(ctx, deadline)
+	var wg sync.WaitGroup
+	for _, c := range pod.Spec.Containers {
+		if c.Lifecycle == nil || c.Lifecycle.PreStop == nil {
+			continue
+		}
+		container := converter.FindContainer(containers, converter.BuildContainerName(key, c.Name))
+		if container == nil || !container.State.Running {
+			continue
+		}
+		wg.Add(1)
+		go func(c corev1.Container) {
+			defer wg.Done()
+			err := p.runHandler(hookCtx, pod, key, c, c.Lifecycle.PreStop)
+			if err != nil {
+				p.log.Info("preStop hook of ", key, "/", c.Name, " failed: ", err.Error())
+			}
+		}(c)
+	}
+	wg.Wait()
+	cancel()
+
+	// stop can take the whole grace period, so it does not hold the shared
+	// connection
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = iopodman.StopPod().Call(ctx, conn, key, stopTimeout(deadline))
+	if err != nil {
+		p.log.Error("error stopPod", "err", err.Error())
+		return errors.VKError(err)
+	}
+	return nil
+}
+
+// killContainer runs preStop hook of the container and then stops it, the
+// same way kubelet kills unhealthy containers. Container gets its stop
+// signal and is killed when the rest of the grace period expires
+func (p podman) killContainer(ctx context.Context, pod *corev1.Pod, key string, c corev1.Container) error {
+	deadline := time.Now().Add(gracePeriod(pod))
+	if c.Lifecycle != nil && c.Lifecycle.PreStop != nil {
+		hookCtx, cancel := context.WithDeadline(ctx, deadline)
+		err := p.runHandler(hookCtx, pod, key, c, c.Lifecycle.PreStop)
+		cancel()
+		if err != nil {
+			p.log.Info("preStop hook of ", key, "/", c.Name, " failed: ", err.Error())
+		}
+	}
+
+	// stop can take the whole grace period, so it does not hold the shared
+	// connection
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = iopodman.StopContainer().Call(ctx, conn, converter.BuildContainerName(key, c.Name), stopTimeout(deadline))
+	if err != nil {
+		p.log.Error("error stopContainer", "err", err.Error())
+		return errors.VKError(err)
+	}
+	return nil
+}
+
+// stopTimeout returns seconds left to the deadline, containers always get
+// at least minGracePeriod to stop
+func stopTimeout(deadline time.Time) int64 {
+	timeout := time.Until(deadline)
+	if timeout < minGracePeriod {
+		timeout = minGracePeriod
+	}
+	return int64((timeout + time.Second - 1) / time.Second)
+}
+
+// postStart runs postStart hooks of the started app containers in the
+// background. Container is not ready until its hook finishes. Container
+// which hook failed is killed and restarted according to the pod
+// restartPolicy
+func (p podman) postStart(pod *corev1.Pod, key string, started ...string) {
+	for _, name := range started {
+		for _, c := range pod.Spec.Containers {
+			if c.Name != name || c.Lifecycle == nil || c.Lifecycle.PostStart == nil {
+				continue
+			}
+			p.hooks.start(key, c.Name)
+			go p.runPostStart(pod, key, c)
+		}
+	}
+}
+
+func (p podman) runPostStart(pod *corev1.Pod, key string, c corev1.Container) {
+	ctx := context.Background()
+	// readiness is reported once the hook finished
+	defer p.changes.notify(key)
+	defer p.hooks.done(key, c.Name)
+	err := p.runHandler(ctx, pod, key, c, c.Lifecycle.PostStart)
+	if err == nil {
+		p.hooks.clear(key, c.Name)
+		return
+	}
+
+	message := fmt.Sprintf("%s for container %q in pod %q failed: %s", handlerDescription(c.Lifecycle.PostStart), c.Name, key, err.Error())
+	p.log.Info(message)
+	p.hooks.failed(key, c.Name, message)
+	if p.recorder != nil {
+		p.recorder.Event(pod, corev1.EventTypeWarning, failedPostStartHook, message)
+	}
+	p.killContainer(ctx, pod, key, c) //nolint:errcheck
+}
+
+// handlerDescription returns lifecycle hook description used in events
+func handlerDescription(handler *corev1.Handler) string {
+	switch {
+	case handler.Exec != nil:
+		return fmt.Sprintf("Exec lifecycle hook (%v)", handler.Exec.Command)
+	case handler.HTTPGet != nil:
+		return fmt.Sprintf("Http lifecycle hook (%s)", handler.HTTPGet.Path)
+	}
+	return "Lifecycle hook"
+}
+
+// setHookStatus reports containers running postStart hook as not ready and
+// containers killed by failed postStart hook
+func (p podman) setHookStatus(key string, pod *corev1.Pod) {
+	for i := range pod.Status.ContainerStatuses {
+		s := &pod.Status.ContainerStatuses[i]
+		if s.State.Running != nil && p.hooks.isRunning(key, s.Name) {
+			s.Ready = false
+		}
+		message := p.hooks.message(key, s.Name)
+		if message == "" {
+			continue
+		}
+		// restarted container waiting for back-off reports the hook failure
+		// in the last state
+		for _, terminated := range []*corev1.ContainerStateTerminated{s.State.Terminated, s.LastTerminationState.Terminated} {
+			if terminated != nil {
+				terminated.Reason = postStartHookError
+				terminated.Message = message
+			}
+		}
+	}
+}
+
+// runHandler runs exec or httpGet lifecycle hook of the container. Hook
+// must finish before the context deadline
+func (p podman) runHandler(ctx context.Context, pod *corev1.Pod, key string, c corev1.Container, handler *corev1.Handler) error {
+	switch {
+	case handler.Exec != nil:
+		code, err := p.exec(ctx, converter.BuildContainerName(key, c.Name), handler.Exec.Command, probeAttach{})
+		if err != nil {
+			return err
+		}
+		return exitError(code)
+	case handler.HTTPGet != nil:
+		timeout := time.Duration(0)
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+		return p.httpGet(ctx, pod, key, c, handler.HTTPGet, timeout)
+	}
+	return fmt.Errorf("unsupported lifecycle handler")
+}
+
+// setTerminatingStatus reports pod, which containers are being stopped
+func (p podman) setTerminatingStatus(key string, pod *corev1.Pod) {
+	if !p.terminations.has(key) {
+		return
+	}
+	pod.Status.Reason = terminatingReason
+	pod.Status.Message = "Pod is terminating"
+}
@@ -0,0 +1,90 @@
+package podman
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGracePeriod(t *testing.T) {
+	spec := int64(10)
+	deletion := int64(5)
+	tests := []struct {
+		name     string
+		spec     *int64
+		deletion *int64
+		want     time.Duration
+	}{
+		{"default", nil, nil, corev1.DefaultTerminationGracePeriodSeconds * time.Second},
+		{"spec", &spec, nil, 10 * time.Second},
+		{"deletion", &spec, &deletion, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{}
+			pod.Spec.TerminationGracePeriodSeconds = tt.spec
+			pod.DeletionGracePeriodSeconds = tt.deletion
+			if got := gracePeriod(pod); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	tests := []struct {
+		name string
+		left time.Duration
+		want int64
+	}{
+		{"expired", -time.Second, 2},
+		{"minimal", time.Second, 2},
+		{"rounded up", 10*time.Second + 100*time.Millisecond, 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stopTimeout(time.Now().Add(tt.left)); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHookTracker(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(*hookTracker)
+		running bool
+		message string
+	}{
+		{"none", func(*hookTracker) {}, false, ""},
+		{"running", func(h *hookTracker) { h.start("ns-pod", "app") }, true, ""},
+		{"done", func(h *hookTracker) {
+			h.start("ns-pod", "app")
+			h.done("ns-pod", "app")
+		}, false, ""},
+		{"failed", func(h *hookTracker) {
+			h.start("ns-pod", "app")
+			h.failed("ns-pod", "app", "hook failed")
+			h.done("ns-pod", "app")
+		}, false, "hook failed"},
+		{"removed", func(h *hookTracker) {
+			h.start("ns-pod", "app")
+			h.failed("ns-pod", "app", "hook failed")
+			h.remove("ns-pod")
+		}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHookTracker()
+			tt.run(h)
+			if got := h.isRunning("ns-pod", "app"); got != tt.running {
+				t.Errorf("running got %v, want %v", got, tt.running)
+			}
+			if got := h.message("ns-pod", "app"); got != tt.message {
+				t.Errorf("message got %q, want %q", got, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/varlink/go/varlink"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+	"github.com/virtual-kubelet/podman/pkg/util/errors"
+)
+
+// partialLogLine is podman log type for the line, which continues in the
+// next log entry
+const partialLogLine = "P"
+
+// GetContainerLogs streams container logs using dedicated varlink connection.
+// Stream is closed when context is cancelled or returned reader is closed
+func (p podman) GetContainerLogs(ctx context.Context, namespace, podName, containerName string, opts api.ContainerLogOpts) (io.ReadCloser, error) {
+	key, err := converter.BuildKeyFromNames(namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	name := converter.BuildContainerName(key, containerName)
+
+	var since string
+	if opts.Since > 0 {
+		since = time.Now().Add(-opts.Since).Format(time.RFC3339Nano)
+	}
+
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	receive, err := iopodman.GetContainersLogs().Send(ctx, conn, varlink.More, []string{name}, false, false, since, int64(opts.Tail), opts.Timestamps)
+	if err != nil {
+		cancel()
+		conn.Close()
+		return nil, errors.VKError(err)
+	}
+
+	// first reply is read synchronously, so missing container is reported
+	// to the caller instead of an empty stream
+	line, flags, err := receive(ctx)
+	if err != nil {
+		cancel()
+		conn.Close()
+		return nil, errors.VKError(err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer conn.Close()
+		defer cancel()
+
+		var w io.Writer = pw
+		if opts.LimitBytes > 0 {
+			w = &limitWriter{w: pw, n: int64(opts.LimitBytes)}
+		}
+		for {
+			if line.Cid != "" || line.Msg != "" {
+				if _, err := io.WriteString(w, formatLogLine(line, opts.Timestamps)); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+			if flags&varlink.Continues == 0 {
+				pw.Close()
+				return
+			}
+			line, flags, err = receive(ctx)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	return &logReader{PipeReader: pr, cancel: cancel}, nil
+}
+
+// formatLogLine returns log line in the format expected by kubelet clients
+func formatLogLine(line iopodman.LogLine, timestamps bool) string {
+	msg := line.Msg
+	if line.ParseLogType != partialLogLine {
+		msg += "\n"
+	}
+	if timestamps {
+		return fmt.Sprintf("%s %s", line.Time, msg)
+	}
+	return msg
+}
+
+// logReader cancels log stream when reader is closed
+type logReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *logReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// limitWriter writes at most n bytes and returns io.EOF afterwards
+type limitWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (l *limitWriter) Write(b []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > l.n {
+		b = b[:l.n]
+	}
+	n, err := l.w.Write(b)
+	l.n -= int64(n)
+	if err == nil && l.n <= 0 {
+		err = io.EOF
+	}
+	return n, err
+}
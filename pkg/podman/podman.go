@@ -4,32 +4,74 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
+	"io"
 	"sync"
 	"time"
 
 	"github.com/varlink/go/varlink"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
 	"go.uber.org/zap"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/virtual-kubelet/podman/pkg/converter"
 	"github.com/virtual-kubelet/podman/pkg/iopodman"
+	"github.com/virtual-kubelet/podman/pkg/manager"
 	"github.com/virtual-kubelet/podman/pkg/util/errors"
 	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
 )
 
 var (
 	// Provider configuration defaults.
-	defaultSocket = "unix:/run/podman/io.podman"
-	defaultSleep  = time.Millisecond * 100
+	defaultSocket   = "unix:/run/podman/io.podman"
+	defaultStateDir = "/var/lib/vkubelet"
+	// defaultPullAuthFile is auth file read by rootful podman service
+	defaultPullAuthFile = "/run/containers/0/auth.json"
+	defaultResolvConf   = "/etc/resolv.conf"
+	defaultSleep        = time.Millisecond * 100
+	// defaultWaitInterval is interval podman checks stopped container
+	defaultWaitInterval = time.Millisecond * 500
 )
 
+// initFailedExitCode is exit code of init container, which could not be
+// created or started, kubelet reports the same
+const initFailedExitCode = 128
+
 // Config defines podman configurables
 type Config struct {
 	Socket *string
-	Log    *zap.SugaredLogger
+	// HostIP is node address reported as pod hostIP
+	HostIP *string
+	// StateDir is node directory where pod volumes are stored
+	StateDir *string
+	// ResourceManager provides configMaps and secrets for pod volumes
+	ResourceManager *manager.ResourceManager
+	// Allocatable is node capacity used as default container limits
+	Allocatable corev1.ResourceList
+	// PidsLimit is default pids limit of the containers, zero means podman
+	// default
+	PidsLimit int64
+	// NativeHealthchecks runs exec liveness probes as podman healthchecks
+	NativeHealthchecks bool
+	// StorageQuota limits container root filesystem by ephemeral-storage
+	// limit, storage driver must support quota
+	StorageQuota bool
+	// AuthFile is node registry credentials file used for all pulls
+	AuthFile *string
+	// PullAuthFile is auth file read by podman service on pulls
+	PullAuthFile *string
+	// ClusterDomain is cluster DNS domain used in pod search domains
+	ClusterDomain string
+	// ClusterDNS is cluster DNS server addresses of the pods
+	ClusterDNS []string
+	// ResolvConf is node resolver configuration used by Default dnsPolicy
+	ResolvConf *string
+	// EventRecorder reports container lifecycle events
+	EventRecorder record.EventRecorder
+	Log           *zap.SugaredLogger
 }
 
 type conn struct {
@@ -38,8 +80,36 @@ type conn struct {
 }
 
 type podman struct {
-	c   *conn
-	log *zap.SugaredLogger
+	c        *conn
+	socket   string
+	hostIP   string
+	stateDir string
+	log      *zap.SugaredLogger
+	restarts *restartTracker
+	probes   *probeManager
+	pulls    *pullTracker
+	inits    *initTracker
+	kills    *killTracker
+
+	terminations *terminationTracker
+	hooks        *hookTracker
+	changes      *changeNotifier
+	recorder     record.EventRecorder
+
+	clusterDomain string
+	clusterDNS    []string
+	resolvConf    string
+
+	resourceManager *manager.ResourceManager
+	allocatable     corev1.ResourceList
+	pidsLimit       int64
+
+	nativeHealthchecks bool
+	storageQuota       bool
+	authFile           string
+	pullAuthFile       string
+	// pullLock serializes image pulls
+	pullLock *sync.Mutex
 }
 
 // Podman is an simplified interface to interfact with
@@ -50,7 +120,13 @@ type Podman interface {
 	Delete(ctx context.Context, pod *corev1.Pod) error
 	GetByName(ctx context.Context, name string) (*corev1.Pod, error)
 	List(ctx context.Context) (*corev1.PodList, error)
+	ListManaged(ctx context.Context, nodeName string) ([]*corev1.Pod, error)
 	GetPodStats(ctx context.Context, pod *corev1.Pod) (*stats.PodStats, error)
+	Sync(ctx context.Context, pod *corev1.Pod) error
+	// Methods using dedicated connection
+	GetContainerLogs(ctx context.Context, namespace, podName, containerName string, opts api.ContainerLogOpts) (io.ReadCloser, error)
+	Exec(ctx context.Context, namespace, podName, containerName string, cmd []string, attach api.AttachIO) error
+	Watch(ctx context.Context) (<-chan string, error)
 	// Methods using above methods
 	Update(ctx context.Context, pod *corev1.Pod) error
 	CreateOrUpdate(ctx context.Context, pod *corev1.Pod) error
@@ -71,11 +147,51 @@ func New(ctx context.Context, c *Config) (Podman, error) {
 		Connection: *vConn,
 	}
 	podman.c = &conn
+	podman.socket = *cfg.Socket
+	if cfg.HostIP != nil {
+		podman.hostIP = *cfg.HostIP
+	}
+	podman.stateDir = *cfg.StateDir
+	podman.resourceManager = cfg.ResourceManager
+	podman.allocatable = cfg.Allocatable
+	podman.pidsLimit = cfg.PidsLimit
+	podman.nativeHealthchecks = cfg.NativeHealthchecks
+	podman.storageQuota = cfg.StorageQuota
+	if cfg.AuthFile != nil {
+		podman.authFile = *cfg.AuthFile
+	}
+	podman.pullAuthFile = *cfg.PullAuthFile
+	podman.pullLock = &sync.Mutex{}
 	podman.log = cfg.Log
+	podman.restarts = newRestartTracker()
+	podman.probes = newProbeManager()
+	podman.pulls = newPullTracker()
+	podman.inits = newInitTracker()
+	podman.kills = newKillTracker()
+	podman.terminations = newTerminationTracker()
+	podman.hooks = newHookTracker()
+	podman.changes = newChangeNotifier()
+	podman.recorder = cfg.EventRecorder
+	podman.clusterDomain = cfg.ClusterDomain
+	podman.clusterDNS = cfg.ClusterDNS
+	podman.resolvConf = *cfg.ResolvConf
+
+	// pull credentials are left in auth file if provider was killed during
+	// pull
+	err = podman.restorePullAuth()
+	if err != nil {
+		podman.log.Error("error restoring auth file", "err", err.Error())
+	}
 
 	return podman, nil
 }
 
+// dial opens new varlink connection, which is not shared with other
+// callers. It is used for long running streaming calls
+func (p podman) dial(ctx context.Context) (*varlink.Connection, error) {
+	return varlink.NewConnection(ctx, p.socket)
+}
+
 func getConfig(c *Config) *Config {
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
@@ -85,6 +201,15 @@ func getConfig(c *Config) *Config {
 		if c.Socket == nil {
 			c.Socket = &defaultSocket
 		}
+		if c.StateDir == nil || *c.StateDir == "" {
+			c.StateDir = &defaultStateDir
+		}
+		if c.PullAuthFile == nil || *c.PullAuthFile == "" {
+			c.PullAuthFile = &defaultPullAuthFile
+		}
+		if c.ResolvConf == nil || *c.ResolvConf == "" {
+			c.ResolvConf = &defaultResolvConf
+		}
 		if c.Log == nil {
 			c.Log = log
 		}
@@ -92,8 +217,11 @@ func getConfig(c *Config) *Config {
 	}
 
 	return &Config{
-		Socket: &defaultSocket,
-		Log:    log,
+		Socket:       &defaultSocket,
+		StateDir:     &defaultStateDir,
+		PullAuthFile: &defaultPullAuthFile,
+		ResolvConf:   &defaultResolvConf,
+		Log:          log,
 	}
 }
 
@@ -118,48 +246,36 @@ func (p podman) Create(ctx context.Context, pod *corev1.Pod) error {
 	}
 
 	p.log.Info("pod created ", "podName ", podmanPodName)
-	// Create hostPath volumes if does not exist
-	for _, volume := range pod.Spec.Volumes {
-		if volume.HostPath != nil {
-			switch *volume.HostPath.Type {
-			case v1.HostPathDirectoryOrCreate:
-				err := os.MkdirAll(volume.HostPath.Path, os.FileMode(0755))
-				if err != nil {
-					return err
-				}
-			case v1.HostPathDirectory:
-				if _, err := os.Stat(volume.HostPath.Path); os.IsNotExist(err) {
-					return fmt.Errorf("volume %s does not exist", volume.Name)
-				}
-			default:
-				p.log.Debug("hostPath volume type %s is not supported", volume.HostPath.Type)
-			}
-		} else {
-			p.log.Debug("volume provider %s is not supported", volume.String())
-		}
+	volumes, err := p.setupVolumes(ctx, pod)
+	if err != nil {
+		p.log.Error("error setupVolumes", "err", err.Error())
+		return err
+	}
+	err = p.setupNetworkFiles(ctx, pod, key)
+	if err != nil {
+		p.log.Error("error setupNetworkFiles", "err", err.Error())
+		return err
 	}
 
-	// add containers in the pod
-	for _, c := range pod.Spec.Containers {
-		p.log.Info("create container ", "pod ", podmanPodName, " container ", c.Name)
-		container := converter.KubeSpecToPodmanContainer(*pod, c, podmanPodName)
+	if len(pod.Spec.InitContainers) > 0 {
+		// init containers might run for a long time, so pod is
+		// initialized in the background and status is reported by reconcile
+		go p.initialize(pod.DeepCopy(), key, volumes)
+		return nil
+	}
 
-		// pull image
-		p.c.Lock()
-		_, err := iopodman.PullImage().Call(ctx, &p.c.Connection, c.Image)
-		p.c.Unlock()
-		if err != nil {
-			p.log.Error("error pullImage", "err", err.Error())
-			return errors.VKError(err)
+	// add containers in the pod. Containers which image could not be
+	// pulled are created by Sync after back-off
+	var created []string
+	for _, c := range pod.Spec.Containers {
+		_, err := p.createContainer(ctx, pod, c, key, volumes)
+		if _, ok := err.(imagePullError); ok {
+			continue
 		}
-
-		p.c.Lock()
-		_, err = iopodman.CreateContainer().Call(ctx, &p.c.Connection, container)
-		p.c.Unlock()
 		if err != nil {
-			p.log.Error("error createContainer", "err", err.Error())
-			return errors.VKError(err)
+			return err
 		}
+		created = append(created, c.Name)
 	}
 
 	// start pod
@@ -170,6 +286,7 @@ func (p podman) Create(ctx context.Context, pod *corev1.Pod) error {
 		p.log.Error("error startPod", "err", err.Error())
 		return errors.VKError(err)
 	}
+	p.postStart(pod.DeepCopy(), key, created...)
 
 	// check pod status
 	retry := 1
@@ -202,6 +319,344 @@ func (p podman) Create(ctx context.Context, pod *corev1.Pod) error {
 	return nil
 }
 
+// createContainer pulls container image and creates container in the pod.
+// It returns ID of the created container
+func (p podman) createContainer(ctx context.Context, pod *corev1.Pod, c corev1.Container, key string, volumes map[string]string) (string, error) {
+	p.log.Info("create container ", "pod ", key, " container ", c.Name)
+	err := p.ensureImage(ctx, pod, c)
+	if err != nil {
+		if pullErr, ok := err.(imagePullError); ok {
+			p.pulls.failed(key, c.Name, c.Image, pullErr)
+			p.changes.notifyAt(key, p.pulls.retryAt(key, c.Name))
+		}
+		return "", err
+	}
+	p.pulls.clear(key, c.Name)
+
+	err = p.verifyNonRoot(ctx, pod, c)
+	if err != nil {
+		p.log.Error("error verifying container user", "err", err.Error())
+		return "", err
+	}
+
+	env, err := p.newEnvResolver(ctx, pod, key).environment(c)
+	if err != nil {
+		p.log.Error("error resolving environment", "err", err.Error())
+		return "", err
+	}
+	container := converter.KubeSpecToPodmanContainer(*pod, expandCommand(c, env), key, volumes, env)
+	if p.pidsLimit > 0 {
+		container.PidsLimit = &p.pidsLimit
+	}
+	if p.storageQuota {
+		converter.SetStorageLimit(c, &container)
+	} else if _, ok := c.Resources.Limits[corev1.ResourceEphemeralStorage]; ok {
+		p.log.Warn("ephemeral-storage limit of container ", c.Name, " is not enforced, storage quota is disabled")
+	}
+	err = p.setNetwork(pod, &container)
+	if err != nil {
+		return "", err
+	}
+	if p.nativeHealthcheck(c) {
+		err = converter.SetHealthcheck(c.LivenessProbe, &container)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	p.c.Lock()
+	id, err := iopodman.CreateContainer().Call(ctx, &p.c.Connection, container)
+	p.c.Unlock()
+	if err != nil {
+		p.log.Error("error createContainer", "err", err.Error())
+		return "", errors.VKError(err)
+	}
+	return id, nil
+}
+
+// initTracker keeps initialization of the pods running in the background
+// and init containers which could not be created or started
+type initTracker struct {
+	sync.Mutex
+	runs   map[string]*initRun
+	failed map[string]map[string]initFailure
+}
+
+// initRun is initialization of the pod, which is cancelled on pod deletion
+type initRun struct {
+	cancel context.CancelFunc
+}
+
+// initFailure is terminated state of the init container, which could not
+// be created or started
+type initFailure struct {
+	reason  string
+	message string
+}
+
+func newInitTracker() *initTracker {
+	return &initTracker{
+		runs:   make(map[string]*initRun),
+		failed: make(map[string]map[string]initFailure),
+	}
+}
+
+// start returns context of the pod initialization, which is done when the
+// pod is stopped
+func (t *initTracker) start(key string) (context.Context, *initRun) {
+	t.Lock()
+	defer t.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &initRun{cancel: cancel}
+	t.runs[key] = run
+	return ctx, run
+}
+
+// end forgets finished initialization of the pod
+func (t *initTracker) end(key string, run *initRun) {
+	t.Lock()
+	defer t.Unlock()
+	run.cancel()
+	if t.runs[key] == run {
+		delete(t.runs, key)
+	}
+}
+
+// stop cancels initialization of the pod and forgets its failures
+func (t *initTracker) stop(key string) {
+	t.Lock()
+	defer t.Unlock()
+	if run := t.runs[key]; run != nil {
+		run.cancel()
+		delete(t.runs, key)
+	}
+	delete(t.failed, key)
+}
+
+// fail records init container, which could not be created or started
+func (t *initTracker) fail(key, container, reason, message string) {
+	t.Lock()
+	defer t.Unlock()
+	if t.failed[key] == nil {
+		t.failed[key] = make(map[string]initFailure)
+	}
+	t.failed[key][container] = initFailure{reason: reason, message: message}
+}
+
+// failure returns failure of the init container
+func (t *initTracker) failure(key, container string) (initFailure, bool) {
+	t.Lock()
+	defer t.Unlock()
+	f, ok := t.failed[key][container]
+	return f, ok
+}
+
+// initialize runs pod init containers in order and creates and starts app
+// containers once all of them completed. It stops when the pod is deleted.
+// Calls on the shared connection are not cancelled, an interrupted call would
+// leave its reply on the connection, only waiting for containers is
+func (p podman) initialize(pod *corev1.Pod, key string, volumes map[string]string) {
+	ctx, run := p.inits.start(key)
+	defer p.inits.end(key, run)
+	initialized, err := p.runInitContainers(ctx, pod, key, volumes)
+	if ctx.Err() != nil {
+		p.log.Info("pod initialization cancelled", " pod ", key)
+		return
+	}
+	if err != nil {
+		p.log.Error("error runInitContainers", " pod ", key, " err ", err.Error())
+		return
+	}
+	if !initialized {
+		p.log.Info("pod initialization failed", " pod ", key)
+		return
+	}
+
+	for _, c := range pod.Spec.Containers {
+		if ctx.Err() != nil {
+			return
+		}
+		id, err := p.createContainer(context.Background(), pod, c, key, volumes)
+		if _, ok := err.(imagePullError); ok {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		// pod is not started as a whole, it would restart init containers
+		p.c.Lock()
+		_, err = iopodman.StartContainer().Call(context.Background(), &p.c.Connection, id)
+		p.c.Unlock()
+		if err != nil {
+			p.log.Error("error startContainer", "err", err.Error())
+			return
+		}
+		p.postStart(pod, key, c.Name)
+	}
+}
+
+// runInitContainers runs init containers one by one and waits for them to
+// complete. Failed init container is restarted with back-off unless pod
+// restartPolicy is Never. It returns false if pod initialization failed
+func (p podman) runInitContainers(ctx context.Context, pod *corev1.Pod, key string, volumes map[string]string) (bool, error) {
+	err := p.startInfra(context.Background(), key)
+	if err != nil {
+		return false, err
+	}
+
+	for _, c := range pod.Spec.InitContainers {
+		id, err := p.pullAndCreate(ctx, pod, c, key, volumes)
+		if err != nil {
+			p.failInit(ctx, pod, key, c.Name, "CreateContainerError", err)
+			return false, err
+		}
+
+		for {
+			p.c.Lock()
+			_, err = iopodman.StartContainer().Call(context.Background(), &p.c.Connection, id)
+			p.c.Unlock()
+			if err != nil {
+				p.failInit(ctx, pod, key, c.Name, "StartError", err)
+				return false, errors.VKError(err)
+			}
+
+			exitCode, err := p.wait(ctx, id)
+			if err != nil {
+				return false, err
+			}
+			if exitCode == 0 {
+				break
+			}
+
+			p.log.Info("init container failed ", "pod ", key, " container ", c.Name, " exitCode ", exitCode)
+			if pod.Spec.RestartPolicy == corev1.RestartPolicyNever {
+				return false, nil
+			}
+			err = sleep(ctx, p.restarts.next(key, id))
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// failInit reports init container, which could not be created or started,
+// as terminated, the same way kubelet does. Initialization is not retried,
+// so the pod fails. Nothing is reported when initialization was cancelled
+func (p podman) failInit(ctx context.Context, pod *corev1.Pod, key, container, reason string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	p.inits.fail(key, container, reason, err.Error())
+	if p.recorder != nil {
+		p.recorder.Eventf(pod, corev1.EventTypeWarning, "Failed", "Error: %s", err.Error())
+	}
+	p.changes.notify(key)
+}
+
+// setInitStatus reports init containers, which could not be created or
+// started, as terminated and the pod as failed
+func (p podman) setInitStatus(key string, pod *corev1.Pod) {
+	failed := false
+	for i := range pod.Status.InitContainerStatuses {
+		s := &pod.Status.InitContainerStatuses[i]
+		f, ok := p.inits.failure(key, s.Name)
+		if !ok {
+			continue
+		}
+		s.State = corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{
+				ExitCode: initFailedExitCode,
+				Reason:   f.reason,
+				Message:  f.message,
+			},
+		}
+		s.Ready = false
+		failed = true
+	}
+	if failed {
+		pod.Status.Phase = corev1.PodFailed
+	}
+}
+
+// sleep waits for the duration or until context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pullAndCreate creates init container and retries failed image pull with
+// back-off until the pod is deleted
+func (p podman) pullAndCreate(ctx context.Context, pod *corev1.Pod, c corev1.Container, key string, volumes map[string]string) (string, error) {
+	for {
+		id, err := p.createContainer(context.Background(), pod, c, key, volumes)
+		if _, ok := err.(imagePullError); !ok {
+			return id, err
+		}
+
+		delay := p.pulls.delay(key, c.Name)
+		if delay < initialBackOff {
+			delay = initialBackOff
+		}
+		err = sleep(ctx, delay)
+		if err != nil {
+			return "", err
+		}
+		if !p.pulls.ready(key, c.Name, time.Now()) {
+			return "", fmt.Errorf("pod %s was deleted", key)
+		}
+	}
+}
+
+// startInfra starts pod infra container, so containers in the pod can be
+// started one by one
+func (p podman) startInfra(ctx context.Context, key string) error {
+	p.c.Lock()
+	podmanPodStatus, err := iopodman.InspectPod().Call(ctx, &p.c.Connection, key)
+	p.c.Unlock()
+	if err != nil {
+		return errors.VKError(err)
+	}
+
+	var status PodmanPod
+	err = json.Unmarshal([]byte(podmanPodStatus), &status)
+	if err != nil {
+		return err
+	}
+	if status.State.InfraContainerID == "" {
+		return nil
+	}
+
+	p.c.Lock()
+	_, err = iopodman.StartContainer().Call(ctx, &p.c.Connection, status.State.InfraContainerID)
+	p.c.Unlock()
+	return errors.VKError(err)
+}
+
+// wait waits for container to stop and returns its exit code. It uses
+// dedicated connection, as container can run for a long time
+func (p podman) wait(ctx context.Context, name string) (int64, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	exitCode, err := iopodman.WaitContainer().Call(ctx, conn, name, int64(defaultWaitInterval/time.Millisecond))
+	if err != nil {
+		return 0, errors.VKError(err)
+	}
+	return exitCode, nil
+}
+
 func (p podman) CreateOrUpdate(ctx context.Context, pod *corev1.Pod) error {
 	if pod == nil {
 		return fmt.Errorf("create pod can't be nil")
@@ -232,13 +687,37 @@ func (p podman) Delete(ctx context.Context, pod *corev1.Pod) error {
 	}
 
 	key := converter.BuildKey(pod)
+	p.terminations.add(key)
+	defer p.terminations.remove(key)
+	// init containers must not be started while the pod is removed
+	p.inits.stop(key)
+	// terminating status is reported while preStop hooks run
+	p.changes.notify(key)
+	// failing probes must not kill containers running preStop hooks
+	p.probes.remove(key)
+	err := p.terminate(ctx, pod, key)
+	if err != nil {
+		p.log.Error("error while stopping pod", " pod ", key, " err ", err.Error())
+	}
+
+	// containers not stopped in time are killed by force removal
 	p.c.Lock()
-	_, err := iopodman.RemovePod().Call(ctx, &p.c.Connection, key, true)
+	_, err = iopodman.RemovePod().Call(ctx, &p.c.Connection, key, true)
 	p.c.Unlock()
 	if err != nil {
 		p.log.Error("error while deleting pod", " pod ", key, " err ", err.Error())
 		return errors.VKError(err)
 	}
+	p.restarts.remove(key)
+	p.pulls.remove(key)
+	p.probes.remove(key)
+	p.hooks.remove(key)
+
+	err = p.removeVolumes(ctx, pod)
+	if err != nil {
+		p.log.Error("error while removing pod volumes", " pod ", key, " err ", err.Error())
+		return err
+	}
 
 	return nil
 }
@@ -272,16 +751,77 @@ func (p podman) GetByName(ctx context.Context, name string) (pod *v1.Pod, err er
 	}
 
 	if len(pPod) > 0 {
-		kpod, err := converter.GetKubePod(pPod)
+		containers, err := p.inspectContainers(ctx, pPod)
+		if err != nil {
+			return nil, errors.VKError(err)
+		}
+		kpod, err := converter.GetKubePod(pPod, containers)
 		if err != nil {
 			return nil, errors.VKError(err)
 		}
+		p.setInitStatus(name, kpod)
+		p.setRestartStatus(name, kpod)
+		p.setPullStatus(name, kpod)
+		p.setHookStatus(name, kpod)
+		p.setProbeStatus(name, kpod)
+		p.setTerminatingStatus(name, kpod)
+		kpod.Status.HostIP = p.hostIP
+		if kpod.Spec.HostNetwork {
+			kpod.Status.PodIP = p.hostIP
+		}
 		return kpod, nil
 	}
 	return nil, errors.VKError(err)
 
 }
 
+// inspectContainers returns inspect json of all containers in the pod
+func (p podman) inspectContainers(ctx context.Context, podmanJSON string) ([]string, error) {
+	var status PodmanPod
+	err := json.Unmarshal([]byte(podmanJSON), &status)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []string
+	for _, c := range status.Containers {
+		p.c.Lock()
+		container, err := iopodman.InspectContainer().Call(ctx, &p.c.Connection, c.ID)
+		p.c.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+// podContainers returns inspect data of all containers in the pod
+func (p podman) podContainers(ctx context.Context, key string) ([]converter.PodmanContainer, error) {
+	p.c.Lock()
+	pPod, err := iopodman.InspectPod().Call(ctx, &p.c.Connection, key)
+	p.c.Unlock()
+	if err != nil {
+		return nil, errors.VKError(err)
+	}
+
+	containersJSON, err := p.inspectContainers(ctx, pPod)
+	if err != nil {
+		return nil, errors.VKError(err)
+	}
+
+	var containers []converter.PodmanContainer
+	for _, containerJSON := range containersJSON {
+		var c converter.PodmanContainer
+		err = json.Unmarshal([]byte(containerJSON), &c)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, c)
+	}
+	return containers, nil
+}
+
 func (p podman) List(ctx context.Context) (podList *corev1.PodList, err error) {
 	p.c.Lock()
 	pPods, err := iopodman.ListPods().Call(ctx, &p.c.Connection)
@@ -302,6 +842,37 @@ func (p podman) List(ctx context.Context) (podList *corev1.PodList, err error) {
 	return kpodsList, nil
 }
 
+// ListManaged returns pods created by the provider on the node. Pods are
+// decoded from podman pods marked by the ownership label, UID is taken from
+// the pod UID label. Pods which fail to load are skipped
+func (p podman) ListManaged(ctx context.Context, nodeName string) ([]*corev1.Pod, error) {
+	p.c.Lock()
+	pPods, err := iopodman.ListPods().Call(ctx, &p.c.Connection)
+	p.c.Unlock()
+	if err != nil {
+		return nil, errors.VKError(err)
+	}
+
+	var pods []*corev1.Pod
+	for _, podData := range pPods {
+		if podData.Labels[converter.ManagedByLabel] != nodeName {
+			continue
+		}
+		kpod, err := p.GetByName(ctx, podData.Name)
+		if errors.IsPodNotFound(err) {
+			// removed meanwhile
+			continue
+		}
+		if err != nil {
+			p.log.Error("error loading managed pod", " pod ", podData.Name, " err ", err.Error())
+			continue
+		}
+		kpod.UID = types.UID(podData.Labels[converter.PodUIDLabel])
+		pods = append(pods, kpod)
+	}
+	return pods, nil
+}
+
 // GetContainerStats return container status from pod name and namespace
 // TODO: Implement sum of rss
 func (p podman) GetPodStats(ctx context.Context, kPod *v1.Pod) (*stats.PodStats, error) {
@@ -0,0 +1,72 @@
+package podman
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestInitTracker(t *testing.T) {
+	i := newInitTracker()
+	ctx, run := i.start("ns-a")
+	other, otherRun := i.start("ns-b")
+	defer i.end("ns-b", otherRun)
+
+	i.stop("ns-a")
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("initialization not cancelled")
+	}
+	if other.Err() != nil {
+		t.Errorf("got %v, want other pod initialization running", other.Err())
+	}
+	// cancelled initialization ends after the pod was created again
+	_, again := i.start("ns-a")
+	i.end("ns-a", run)
+	if i.runs["ns-a"] != again {
+		t.Errorf("ended initialization removed the new one")
+	}
+	i.end("ns-a", again)
+	if len(i.runs) != 1 {
+		t.Errorf("got %d runs, want 1", len(i.runs))
+	}
+}
+
+func TestSetInitStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		container string
+		want      corev1.PodPhase
+	}{
+		{"no failure", "", corev1.PodPending},
+		{"start failure", "init", corev1.PodFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := podman{inits: newInitTracker()}
+			if tt.container != "" {
+				p.inits.fail("ns-web", tt.container, "StartError", "no such file")
+			}
+			pod := &corev1.Pod{Status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				InitContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "init",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}},
+				}},
+			}}
+			p.setInitStatus("ns-web", pod)
+			if pod.Status.Phase != tt.want {
+				t.Errorf("got %v, want %v", pod.Status.Phase, tt.want)
+			}
+			s := pod.Status.InitContainerStatuses[0].State
+			if (s.Terminated != nil) != (tt.container != "") {
+				t.Fatalf("got %+v, want terminated %v", s, tt.container != "")
+			}
+			if s.Terminated != nil && (s.Terminated.Reason != "StartError" || s.Terminated.ExitCode != initFailedExitCode) {
+				t.Errorf("got %+v, want StartError with exit code %d", s.Terminated, initFailedExitCode)
+			}
+		})
+	}
+}
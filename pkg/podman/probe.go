@@ -0,0 +1,484 @@
+package podman
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+)
+
+// probeType is type of the container probe
+type probeType int
+
+const (
+	liveness probeType = iota
+	readiness
+)
+
+func (t probeType) String() string {
+	if t == liveness {
+		return "liveness"
+	}
+	return "readiness"
+}
+
+const (
+	// probe defaults, same as set by api server
+	defaultProbePeriod           = 10 * time.Second
+	defaultProbeTimeout          = time.Second
+	defaultProbeSuccessThreshold = 1
+	defaultProbeFailureThreshold = 3
+	// probeUserAgent is user agent of http probes
+	probeUserAgent = "kube-probe/podman"
+	// healthcheckUnhealthy is podman healthcheck status of failing container
+	healthcheckUnhealthy = "unhealthy"
+)
+
+// probeKey identifies probe worker of the pod
+type probeKey struct {
+	container string
+	probeType probeType
+}
+
+// probeManager runs container probes of the pods in the background and
+// keeps their results
+type probeManager struct {
+	sync.Mutex
+	pods map[string]map[probeKey]*probeWorker
+}
+
+func newProbeManager() *probeManager {
+	return &probeManager{
+		pods: map[string]map[probeKey]*probeWorker{},
+	}
+}
+
+// sync starts probe workers of the pod containers and passes them current
+// state of the containers
+func (m *probeManager) sync(p podman, pod *corev1.Pod, key string, containers []converter.PodmanContainer) {
+	m.Lock()
+	defer m.Unlock()
+
+	workers, ok := m.pods[key]
+	if !ok {
+		workers = map[probeKey]*probeWorker{}
+		m.pods[key] = workers
+	}
+
+	for _, spec := range pod.Spec.Containers {
+		probes := map[probeType]*corev1.Probe{
+			liveness:  spec.LivenessProbe,
+			readiness: spec.ReadinessProbe,
+		}
+		if p.nativeHealthcheck(spec) {
+			delete(probes, liveness)
+		}
+		for t, probe := range probes {
+			if probe == nil {
+				continue
+			}
+			k := probeKey{container: spec.Name, probeType: t}
+			w, ok := workers[k]
+			if !ok {
+				w = newProbeWorker(p, pod, key, spec, t, probe)
+				workers[k] = w
+				go w.run()
+			}
+
+			var startedAt time.Time
+			c := converter.FindContainer(containers, converter.BuildContainerName(key, spec.Name))
+			if c != nil && c.State.Running {
+				startedAt = c.State.StartedAt
+			}
+			w.update(startedAt)
+		}
+	}
+}
+
+// ready returns readiness probe result of the container. Container is not
+// ready until its probe worker is started
+func (m *probeManager) ready(key, container string) bool {
+	m.Lock()
+	defer m.Unlock()
+	w, ok := m.pods[key][probeKey{container: container, probeType: readiness}]
+	if !ok {
+		return false
+	}
+	return w.getResult()
+}
+
+// remove stops probe workers of the pod
+func (m *probeManager) remove(key string) {
+	m.Lock()
+	defer m.Unlock()
+	for _, w := range m.pods[key] {
+		close(w.stop)
+	}
+	delete(m.pods, key)
+}
+
+// setProbeStatus reports readiness of the running containers with readiness
+// probe and updates pod ready conditions. Containers running postStart hook
+// stay not ready
+func (p podman) setProbeStatus(key string, pod *corev1.Pod) {
+	for i := range pod.Status.ContainerStatuses {
+		s := &pod.Status.ContainerStatuses[i]
+		if s.State.Running == nil || p.hooks.isRunning(key, s.Name) {
+			continue
+		}
+		for _, spec := range pod.Spec.Containers {
+			if spec.Name == s.Name && spec.ReadinessProbe != nil {
+				s.Ready = p.probes.ready(key, s.Name)
+			}
+		}
+	}
+	converter.SetReadyConditions(pod, &pod.Status)
+}
+
+// probeWorker periodically probes the container. Probe result is changed
+// once the same outcome is observed threshold times in a row
+type probeWorker struct {
+	sync.Mutex
+	p         podman
+	pod       *corev1.Pod
+	key       string
+	container corev1.Container
+	probeType probeType
+	spec      *corev1.Probe
+	stop      chan struct{}
+
+	startedAt  time.Time
+	result     bool
+	lastResult bool
+	resultRun  int
+	// onHold stops probing of the container killed by liveness probe until
+	// it is started again
+	onHold bool
+}
+
+func newProbeWorker(p podman, pod *corev1.Pod, key string, container corev1.Container, t probeType, spec *corev1.Probe) *probeWorker {
+	w := &probeWorker{
+		p:         p,
+		pod:       pod.DeepCopy(),
+		key:       key,
+		container: container,
+		probeType: t,
+		spec:      spec,
+		stop:      make(chan struct{}),
+	}
+	w.reset()
+	return w
+}
+
+// reset sets initial result of the probe. Containers are not ready until
+// probe succeeds and alive until probe fails
+func (w *probeWorker) reset() {
+	w.result = w.probeType == liveness
+	w.lastResult = w.result
+	w.resultRun = 0
+	w.onHold = false
+}
+
+// update sets start time of the running container, zero time if container
+// is not running. Probe state is reset when container is restarted
+func (w *probeWorker) update(startedAt time.Time) {
+	w.Lock()
+	defer w.Unlock()
+	if !startedAt.Equal(w.startedAt) {
+		w.startedAt = startedAt
+		w.reset()
+	}
+}
+
+func (w *probeWorker) getResult() bool {
+	w.Lock()
+	defer w.Unlock()
+	return w.result
+}
+
+func (w *probeWorker) run() {
+	period := defaultProbePeriod
+	if w.spec.PeriodSeconds > 0 {
+		period = time.Duration(w.spec.PeriodSeconds) * time.Second
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		w.doProbe()
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// doProbe probes the container once and updates the probe result
+func (w *probeWorker) doProbe() {
+	w.Lock()
+	startedAt, onHold := w.startedAt, w.onHold
+	w.Unlock()
+	if startedAt.IsZero() || onHold {
+		return
+	}
+	if time.Since(startedAt) < time.Duration(w.spec.InitialDelaySeconds)*time.Second {
+		return
+	}
+
+	timeout := defaultProbeTimeout
+	if w.spec.TimeoutSeconds > 0 {
+		timeout = time.Duration(w.spec.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	err := w.probe(ctx, timeout)
+	cancel()
+	success := err == nil
+	if err != nil {
+		w.p.log.Debug("probe failed ", "pod ", w.key, " container ", w.container.Name, " probe ", w.probeType.String(), " err ", err.Error())
+	}
+
+	w.Lock()
+	if !startedAt.Equal(w.startedAt) {
+		// container was restarted while probing
+		w.Unlock()
+		return
+	}
+	if success == w.lastResult {
+		w.resultRun++
+	} else {
+		w.lastResult = success
+		w.resultRun = 1
+	}
+	threshold := int32(defaultProbeFailureThreshold)
+	if w.spec.FailureThreshold > 0 {
+		threshold = w.spec.FailureThreshold
+	}
+	if success {
+		threshold = defaultProbeSuccessThreshold
+		if w.spec.SuccessThreshold > 0 {
+			threshold = w.spec.SuccessThreshold
+		}
+	}
+	changed := false
+	if int32(w.resultRun) >= threshold {
+		changed = w.result != success
+		w.result = success
+	}
+	kill := w.probeType == liveness && !w.result
+	if kill {
+		w.onHold = true
+	}
+	w.Unlock()
+
+	// liveness failure is reported by events of the killed container
+	if changed && w.probeType == readiness {
+		w.p.changes.notify(w.key)
+	}
+	if kill {
+		w.p.killUnhealthy(w.pod, w.key, w.container)
+	}
+}
+
+// killTracker keeps containers being killed grouped by pod key, so repeated
+// failures do not kill the same container twice
+type killTracker struct {
+	sync.Mutex
+	pods map[string]map[string]bool
+}
+
+func newKillTracker() *killTracker {
+	return &killTracker{
+		pods: make(map[string]map[string]bool),
+	}
+}
+
+// run runs kill of the container in the background. It returns false if
+// the container is already being killed
+func (t *killTracker) run(key, container string, kill func()) bool {
+	t.Lock()
+	defer t.Unlock()
+	if t.pods[key][container] {
+		return false
+	}
+	if t.pods[key] == nil {
+		t.pods[key] = make(map[string]bool)
+	}
+	t.pods[key][container] = true
+
+	go func() {
+		defer t.end(key, container)
+		kill()
+	}()
+	return true
+}
+
+func (t *killTracker) end(key, container string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.pods[key], container)
+	if len(t.pods[key]) == 0 {
+		delete(t.pods, key)
+	}
+}
+
+// killUnhealthy kills container failing liveness probe in the background,
+// its preStop hook is run first. Container is then restarted by Sync
+// according to the pod restartPolicy
+func (p podman) killUnhealthy(pod *corev1.Pod, key string, c corev1.Container) {
+	pod = pod.DeepCopy()
+	p.kills.run(key, c.Name, func() {
+		p.log.Info("container failed liveness probe, will be restarted ", "pod ", key, " container ", c.Name)
+		p.killContainer(context.Background(), pod, key, c) //nolint:errcheck
+	})
+}
+
+// nativeHealthcheck returns true if container liveness probe is run by
+// podman healthcheck instead of the probe worker
+func (p podman) nativeHealthcheck(c corev1.Container) bool {
+	return p.nativeHealthchecks && c.LivenessProbe != nil && c.LivenessProbe.Exec != nil
+}
+
+// probe runs the probe handler. Nil is returned if probe succeeded
+func (w *probeWorker) probe(ctx context.Context, timeout time.Duration) error {
+	handler := w.spec.Handler
+	switch {
+	case handler.Exec != nil:
+		return w.probeExec(ctx, handler.Exec)
+	case handler.HTTPGet != nil:
+		return w.p.httpGet(ctx, w.pod, w.key, w.container, handler.HTTPGet, timeout)
+	case handler.TCPSocket != nil:
+		return w.probeTCP(ctx, handler.TCPSocket, timeout)
+	}
+	return fmt.Errorf("missing probe handler")
+}
+
+func (w *probeWorker) probeExec(ctx context.Context, action *corev1.ExecAction) error {
+	name := converter.BuildContainerName(w.key, w.container.Name)
+	code, err := w.p.exec(ctx, name, action.Command, probeAttach{})
+	if err != nil {
+		return err
+	}
+	return exitError(code)
+}
+
+// httpGet sends GET request of the probe or lifecycle hook to the container.
+// Request succeeds with status code from 200 to 399
+func (p podman) httpGet(ctx context.Context, pod *corev1.Pod, key string, c corev1.Container, action *corev1.HTTPGetAction, timeout time.Duration) error {
+	host, port, err := p.address(pod, key, c, action.Host, action.Port)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if action.Scheme == corev1.URISchemeHTTPS {
+		scheme = "https"
+	}
+	u := &url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+	}
+	u, err = u.Parse(action.Path)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", probeUserAgent)
+	for _, h := range action.HTTPHeaders {
+		if h.Name == "Host" {
+			req.Host = h.Value
+			continue
+		}
+		req.Header.Add(h.Name, h.Value)
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// same as kubelet, probes do not verify certificates
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("HTTP request failed with statuscode: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *probeWorker) probeTCP(ctx context.Context, action *corev1.TCPSocketAction, timeout time.Duration) error {
+	host, port, err := w.p.address(w.pod, w.key, w.container, action.Host, action.Port)
+	if err != nil {
+		return err
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// address returns host and port of the network probe or lifecycle hook.
+// Host defaults to the pod IP and named port is resolved from container
+// ports
+func (p podman) address(pod *corev1.Pod, key string, c corev1.Container, host string, port intstr.IntOrString) (string, int, error) {
+	number := port.IntValue()
+	if port.Type == intstr.String {
+		number = 0
+		for _, cp := range c.Ports {
+			if cp.Name == port.StrVal {
+				number = int(cp.ContainerPort)
+			}
+		}
+	}
+	if number <= 0 || number > 65535 {
+		return "", 0, fmt.Errorf("invalid port %s", port.String())
+	}
+
+	if host != "" {
+		return host, number, nil
+	}
+	if pod.Spec.HostNetwork {
+		return p.hostIP, number, nil
+	}
+	ctx := context.Background()
+	containers, err := p.podContainers(ctx, key)
+	if err != nil {
+		return "", 0, err
+	}
+	podIP := converter.GetPodIP(containers)
+	if podIP == "" {
+		return "", 0, fmt.Errorf("pod %s has no IP address", key)
+	}
+	return podIP, number, nil
+}
+
+// probeAttach discards output of exec probes
+type probeAttach struct{}
+
+func (probeAttach) Stdin() io.Reader            { return nil }
+func (probeAttach) Stdout() io.WriteCloser      { return nil }
+func (probeAttach) Stderr() io.WriteCloser      { return nil }
+func (probeAttach) TTY() bool                   { return false }
+func (probeAttach) Resize() <-chan api.TermSize { return nil }
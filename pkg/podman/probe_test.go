@@ -0,0 +1,62 @@
+package podman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKillTracker(t *testing.T) {
+	k := newKillTracker()
+	// kill blocked by slow preStop hook
+	hook := make(chan struct{})
+	killed := make(chan string, 3)
+	blocking := func(name string) func() {
+		return func() {
+			<-hook
+			killed <- name
+		}
+	}
+
+	start := time.Now()
+	if !k.run("ns-a", "app", blocking("ns-a/app")) {
+		t.Fatalf("got false, want true")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("run blocked by the kill")
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		container string
+		want      bool
+	}{
+		{"same container", "ns-a", "app", false},
+		{"other container", "ns-a", "sidecar", true},
+		{"other pod", "ns-b", "app", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.run(tt.key, tt.container, blocking(tt.key+"/"+tt.container)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	close(hook)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-killed:
+		case <-time.After(time.Second):
+			t.Fatalf("kill did not finish")
+		}
+	}
+	// finished kill can run again
+	deadline := time.Now().Add(time.Second)
+	for !k.run("ns-a", "app", func() {}) {
+		if time.Now().After(deadline) {
+			t.Fatalf("kill not released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
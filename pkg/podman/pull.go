@@ -0,0 +1,323 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+	"github.com/virtual-kubelet/podman/pkg/util/errors"
+)
+
+const (
+	// image pull failure reasons, same as reported by kubelet
+	errImagePull      = "ErrImagePull"
+	imagePullBackOff  = "ImagePullBackOff"
+	errImageNeverPull = "ErrImageNeverPull"
+)
+
+// imagePullError is failure to get container image
+type imagePullError struct {
+	reason  string
+	message string
+}
+
+func (e imagePullError) Error() string {
+	return e.message
+}
+
+// pullFailure is state of the container, which image could not be pulled
+type pullFailure struct {
+	imagePullError
+	image    string
+	at       time.Time
+	delay    time.Duration
+	reported bool
+}
+
+// pullTracker keeps image pull failures of containers grouped by pod key.
+// Failed pulls are retried with back-off
+type pullTracker struct {
+	sync.Mutex
+	pods map[string]map[string]*pullFailure
+	// retrying keeps keys of pods, which pulls are retried in the background
+	retrying map[string]bool
+}
+
+func newPullTracker() *pullTracker {
+	return &pullTracker{
+		pods:     make(map[string]map[string]*pullFailure),
+		retrying: make(map[string]bool),
+	}
+}
+
+// startRetry marks pulls of the pod retried. It returns false if they are
+// already retried
+func (t *pullTracker) startRetry(key string) bool {
+	t.Lock()
+	defer t.Unlock()
+	if t.retrying[key] {
+		return false
+	}
+	t.retrying[key] = true
+	return true
+}
+
+// endRetry marks pulls of the pod not retried anymore
+func (t *pullTracker) endRetry(key string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.retrying, key)
+}
+
+// failed records failed pull of the container image and increases its
+// back-off
+func (t *pullTracker) failed(key, container, image string, err imagePullError) {
+	t.Lock()
+	defer t.Unlock()
+	if t.pods[key] == nil {
+		t.pods[key] = make(map[string]*pullFailure)
+	}
+	f := t.pods[key][container]
+	if f == nil || f.image != image {
+		f = &pullFailure{image: image}
+		t.pods[key][container] = f
+	}
+	f.imagePullError = err
+	f.at = time.Now()
+	f.reported = false
+	// image might be loaded to the node manually, so missing image is
+	// checked again without back-off
+	if err.reason != errImageNeverPull {
+		f.delay *= 2
+		if f.delay == 0 {
+			f.delay = initialBackOff
+		}
+		if f.delay > maxBackOff {
+			f.delay = maxBackOff
+		}
+	}
+}
+
+// delay returns back-off of the next pull of the container image
+func (t *pullTracker) delay(key, container string) time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	if f := t.pods[key][container]; f != nil {
+		return f.delay
+	}
+	return 0
+}
+
+// ready returns true if the failed pull should be retried
+func (t *pullTracker) ready(key, container string, now time.Time) bool {
+	t.Lock()
+	defer t.Unlock()
+	f := t.pods[key][container]
+	return f != nil && !now.Before(f.at.Add(f.delay))
+}
+
+// retryAt returns time the failed pull of the container image should be
+// retried. Zero time is returned if pull did not fail
+func (t *pullTracker) retryAt(key, container string) time.Time {
+	t.Lock()
+	defer t.Unlock()
+	if f := t.pods[key][container]; f != nil {
+		return f.at.Add(f.delay)
+	}
+	return time.Time{}
+}
+
+// clear forgets failed pull of the container
+func (t *pullTracker) clear(key, container string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.pods[key], container)
+}
+
+// remove forgets all containers of the pod
+func (t *pullTracker) remove(key string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.pods, key)
+}
+
+// waiting returns waiting state of the container with failed pull. Pull
+// error is reported once, back-off is reported until the next pull
+func (t *pullTracker) waiting(key, container string) *corev1.ContainerStateWaiting {
+	t.Lock()
+	defer t.Unlock()
+	f := t.pods[key][container]
+	if f == nil {
+		return nil
+	}
+	waiting := &corev1.ContainerStateWaiting{
+		Reason:  f.reason,
+		Message: f.message,
+	}
+	if f.reason == errImagePull && f.reported {
+		waiting.Reason = imagePullBackOff
+		waiting.Message = fmt.Sprintf("Back-off pulling image %q", f.image)
+	}
+	f.reported = true
+	return waiting
+}
+
+// ensureImage makes container image present on the node according to the
+// container imagePullPolicy
+func (p podman) ensureImage(ctx context.Context, pod *corev1.Pod, c corev1.Container) error {
+	policy := c.ImagePullPolicy
+	if policy == "" {
+		policy = defaultPullPolicy(c.Image)
+	}
+
+	if policy != corev1.PullAlways {
+		p.c.Lock()
+		exists, err := iopodman.ImageExists().Call(ctx, &p.c.Connection, c.Image)
+		p.c.Unlock()
+		if err != nil {
+			return imagePullError{reason: errImagePull, message: err.Error()}
+		}
+		// podman reports 0 if image exists
+		if exists == 0 {
+			return nil
+		}
+		if policy == corev1.PullNever {
+			return imagePullError{
+				reason:  errImageNeverPull,
+				message: fmt.Sprintf("Container image %q is not present with pull policy of Never", c.Image),
+			}
+		}
+	}
+
+	err := p.pullImage(ctx, pod, c.Image)
+	if err != nil {
+		p.log.Error("error pullImage", "err", err.Error())
+		return imagePullError{
+			reason:  errImagePull,
+			message: fmt.Sprintf("Failed to pull image %q: %s", c.Image, err.Error()),
+		}
+	}
+	return nil
+}
+
+// pullImage pulls the image with pull credentials of the pod. Pull can take
+// minutes, so it does not hold the shared connection. Pulls are serialized
+// by pull lock, as credentials are handed to podman through the auth file
+func (p podman) pullImage(ctx context.Context, pod *corev1.Pod, image string) error {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	auths := p.pullCredentials(pod)
+	p.pullLock.Lock()
+	defer p.pullLock.Unlock()
+	restore, err := p.writePullAuth(image, auths)
+	if err != nil {
+		p.log.Error("error writing pull credentials", "err", err.Error())
+	}
+	_, err = iopodman.PullImage().Call(ctx, conn, image)
+	restore()
+	return err
+}
+
+// defaultPullPolicy returns pull policy of the image, same as defaulted by
+// api server
+func defaultPullPolicy(image string) corev1.PullPolicy {
+	if strings.Contains(image, "@") {
+		return corev1.PullIfNotPresent
+	}
+	i := strings.LastIndex(image, ":")
+	if i >= 0 && !strings.Contains(image[i:], "/") && image[i+1:] != "latest" {
+		return corev1.PullIfNotPresent
+	}
+	return corev1.PullAlways
+}
+
+// retryPulls creates and starts app containers, which image pull back-off
+// expired. Pulls can take minutes, so they are retried in the background
+// and pod change is notified when they are done
+func (p podman) retryPulls(pod *corev1.Pod, key string, containers []converter.PodmanContainer) {
+	now := time.Now()
+	var retry []corev1.Container
+	for _, spec := range pod.Spec.Containers {
+		if converter.FindContainer(containers, converter.BuildContainerName(key, spec.Name)) != nil {
+			continue
+		}
+		if !p.pulls.ready(key, spec.Name, now) {
+			if at := p.pulls.retryAt(key, spec.Name); !at.IsZero() {
+				p.changes.notifyAt(key, at)
+			}
+			continue
+		}
+		retry = append(retry, spec)
+	}
+	if len(retry) == 0 || !p.pulls.startRetry(key) {
+		return
+	}
+
+	go func() {
+		defer p.pulls.endRetry(key)
+		err := p.createRetried(context.Background(), pod, key, retry)
+		if err != nil {
+			p.log.Error("error retryPulls", " pod ", key, " err ", err.Error())
+		}
+		p.changes.notify(key)
+	}()
+}
+
+// createRetried creates and starts app containers, which image pull is
+// retried
+func (p podman) createRetried(ctx context.Context, pod *corev1.Pod, key string, retry []corev1.Container) error {
+	volumes, err := p.setupVolumes(ctx, pod)
+	if err != nil {
+		return err
+	}
+	err = p.setupNetworkFiles(ctx, pod, key)
+	if err != nil {
+		return err
+	}
+	for _, spec := range retry {
+		if p.terminations.has(key) {
+			return nil
+		}
+		id, err := p.createContainer(ctx, pod, spec, key, volumes)
+		if _, ok := err.(imagePullError); ok {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		p.c.Lock()
+		_, err = iopodman.StartContainer().Call(ctx, &p.c.Connection, id)
+		p.c.Unlock()
+		if err != nil {
+			p.log.Error("error startContainer", "err", err.Error())
+			return errors.VKError(err)
+		}
+		p.postStart(pod.DeepCopy(), key, spec.Name)
+	}
+	return nil
+}
+
+// setPullStatus reports containers with failed image pull as waiting
+func (p podman) setPullStatus(key string, pod *corev1.Pod) {
+	statuses := [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses}
+	for _, s := range statuses {
+		for i := range s {
+			if s[i].State.Waiting == nil {
+				continue
+			}
+			if waiting := p.pulls.waiting(key, s[i].Name); waiting != nil {
+				s[i].State.Waiting = waiting
+			}
+		}
+	}
+}
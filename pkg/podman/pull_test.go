@@ -0,0 +1,113 @@
+package podman
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestDefaultPullPolicy(t *testing.T) {
+	tests := []struct {
+		image string
+		want  corev1.PullPolicy
+	}{
+		{"nginx", corev1.PullAlways},
+		{"nginx:latest", corev1.PullAlways},
+		{"nginx:1.17", corev1.PullIfNotPresent},
+		{"registry:5000/nginx", corev1.PullAlways},
+		{"registry:5000/nginx:1.17", corev1.PullIfNotPresent},
+		{"nginx@sha256:abc", corev1.PullIfNotPresent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := defaultPullPolicy(tt.image); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPullTrackerBackOff(t *testing.T) {
+	pullErr := imagePullError{reason: errImagePull, message: "failed"}
+	neverErr := imagePullError{reason: errImageNeverPull, message: "not present"}
+	tests := []struct {
+		name   string
+		errs   []imagePullError
+		images []string
+		want   time.Duration
+	}{
+		{"first failure", []imagePullError{pullErr}, []string{"a"}, initialBackOff},
+		{"doubled", []imagePullError{pullErr, pullErr, pullErr}, []string{"a", "a", "a"}, 4 * initialBackOff},
+		{"capped", repeatErr(pullErr, 20), nil, maxBackOff},
+		{"image changed", []imagePullError{pullErr, pullErr}, []string{"a", "b"}, initialBackOff},
+		{"never pull", []imagePullError{neverErr, neverErr}, []string{"a", "a"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newPullTracker()
+			for i, err := range tt.errs {
+				image := "a"
+				if i < len(tt.images) {
+					image = tt.images[i]
+				}
+				tracker.failed("pod", "app", image, err)
+			}
+			if got := tracker.delay("pod", "app"); got != tt.want {
+				t.Errorf("got delay %v, want %v", got, tt.want)
+			}
+			now := time.Now()
+			if got := tracker.ready("pod", "app", now.Add(tt.want)); !got {
+				t.Errorf("got not ready after back-off")
+			}
+			if tt.want > 0 && tracker.ready("pod", "app", now) {
+				t.Errorf("got ready before back-off")
+			}
+		})
+	}
+}
+
+func repeatErr(err imagePullError, n int) []imagePullError {
+	errs := make([]imagePullError, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+func TestPullTrackerWaiting(t *testing.T) {
+	tracker := newPullTracker()
+	if got := tracker.waiting("pod", "app"); got != nil {
+		t.Fatalf("got waiting %v without failure", got)
+	}
+	tracker.failed("pod", "app", "nginx", imagePullError{reason: errImagePull, message: "failed"})
+
+	wantReasons := []string{errImagePull, imagePullBackOff, imagePullBackOff}
+	for i, want := range wantReasons {
+		if got := tracker.waiting("pod", "app"); got == nil || got.Reason != want {
+			t.Errorf("report %d: got %v, want reason %s", i, got, want)
+		}
+	}
+
+	tracker.clear("pod", "app")
+	if got := tracker.waiting("pod", "app"); got != nil {
+		t.Errorf("got waiting %v after clear", got)
+	}
+}
+
+func TestPullTrackerRetry(t *testing.T) {
+	tracker := newPullTracker()
+	if !tracker.startRetry("pod") {
+		t.Fatal("first retry not started")
+	}
+	if tracker.startRetry("pod") {
+		t.Error("concurrent retry started")
+	}
+	if !tracker.startRetry("other") {
+		t.Error("retry of other pod not started")
+	}
+	tracker.endRetry("pod")
+	if !tracker.startRetry("pod") {
+		t.Error("retry not started after previous ended")
+	}
+}
@@ -0,0 +1,193 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+	"github.com/virtual-kubelet/podman/pkg/util/errors"
+)
+
+const (
+	// initialBackOff and maxBackOff are restart back-off limits, same as
+	// used by kubelet
+	initialBackOff = 10 * time.Second
+	maxBackOff     = 5 * time.Minute
+	// backOffReset is container run time after which back-off is reset
+	backOffReset = 2 * maxBackOff
+)
+
+// backOff is restart state of the container
+type backOff struct {
+	restarts int32
+	delay    time.Duration
+}
+
+// restartTracker keeps back-off of restarted containers grouped by pod key
+type restartTracker struct {
+	sync.Mutex
+	pods map[string]map[string]*backOff
+}
+
+func newRestartTracker() *restartTracker {
+	return &restartTracker{
+		pods: make(map[string]map[string]*backOff),
+	}
+}
+
+// ready returns true if back-off of exited container expired
+func (r *restartTracker) ready(key, id string, startedAt, finishedAt, now time.Time) bool {
+	r.Lock()
+	defer r.Unlock()
+	b := r.pods[key][id]
+	if b == nil {
+		return true
+	}
+	if finishedAt.Sub(startedAt) > backOffReset {
+		b.delay = 0
+	}
+	return !now.Before(finishedAt.Add(b.delay))
+}
+
+// next records container restart and increases its back-off
+func (r *restartTracker) next(key, id string) time.Duration {
+	r.Lock()
+	defer r.Unlock()
+	if r.pods[key] == nil {
+		r.pods[key] = make(map[string]*backOff)
+	}
+	b := r.pods[key][id]
+	if b == nil {
+		b = &backOff{}
+		r.pods[key][id] = b
+	}
+	b.restarts++
+	b.delay *= 2
+	if b.delay == 0 {
+		b.delay = initialBackOff
+	}
+	if b.delay > maxBackOff {
+		b.delay = maxBackOff
+	}
+	return b.delay
+}
+
+// get returns restart count and current back-off of the container
+func (r *restartTracker) get(key, id string) (int32, time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	b := r.pods[key][id]
+	if b == nil {
+		return 0, 0
+	}
+	return b.restarts, b.delay
+}
+
+// remove forgets all containers of the pod
+func (r *restartTracker) remove(key string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.pods, key)
+}
+
+// Sync refreshes pod state and restarts exited app containers of the pod
+// according to the pod restartPolicy, with exponential back-off
+func (p podman) Sync(ctx context.Context, pod *corev1.Pod) error {
+	key := converter.BuildKey(pod)
+	// stopped containers of terminating pod are not restarted
+	if p.terminations.has(key) {
+		return nil
+	}
+	err := p.syncVolumes(pod)
+	if err != nil {
+		p.log.Error("error syncVolumes", " pod ", key, " err ", err.Error())
+	}
+
+	containers, err := p.podContainers(ctx, key)
+	if err != nil {
+		return err
+	}
+	err = p.syncHostsFile(pod, containers)
+	if err != nil {
+		p.log.Error("error syncHostsFile", " pod ", key, " err ", err.Error())
+	}
+	p.probes.sync(p, pod, key, containers)
+	p.retryPulls(pod.DeepCopy(), key, containers)
+
+	now := time.Now()
+	for _, spec := range pod.Spec.Containers {
+		c := converter.FindContainer(containers, converter.BuildContainerName(key, spec.Name))
+		// podman runs the healthcheck, provider only reacts to the result
+		if c != nil && c.State.Running && p.nativeHealthcheck(spec) && c.State.Healthcheck.Status == healthcheckUnhealthy {
+			p.log.Info("container healthcheck failed ", "pod ", key, " container ", spec.Name, " failingStreak ", c.State.Healthcheck.FailingStreak)
+			p.killUnhealthy(pod, key, spec)
+			continue
+		}
+		if c == nil || !exited(*c) {
+			continue
+		}
+		if !converter.ShouldRestart(pod, c.State.ExitCode) {
+			continue
+		}
+		if !p.restarts.ready(key, c.ID, c.State.StartedAt, c.State.FinishedAt, now) {
+			_, delay := p.restarts.get(key, c.ID)
+			p.changes.notifyAt(key, c.State.FinishedAt.Add(delay))
+			continue
+		}
+
+		p.log.Info("restart container ", "pod ", key, " container ", spec.Name, " exitCode ", c.State.ExitCode)
+		p.c.Lock()
+		_, err := iopodman.StartContainer().Call(ctx, &p.c.Connection, c.ID)
+		p.c.Unlock()
+		if err != nil {
+			p.log.Error("error startContainer", "err", err.Error())
+			return errors.VKError(err)
+		}
+		p.restarts.next(key, c.ID)
+		p.postStart(pod.DeepCopy(), key, spec.Name)
+	}
+	return nil
+}
+
+// setRestartStatus sets restart count of pod containers and reports
+// containers waiting for restart as CrashLoopBackOff
+func (p podman) setRestartStatus(key string, pod *corev1.Pod) {
+	for i := range pod.Status.InitContainerStatuses {
+		s := &pod.Status.InitContainerStatuses[i]
+		failed := s.State.Terminated != nil && s.State.Terminated.ExitCode != 0
+		p.setContainerRestartStatus(key, pod, s, failed && pod.Spec.RestartPolicy != corev1.RestartPolicyNever)
+	}
+	for i := range pod.Status.ContainerStatuses {
+		s := &pod.Status.ContainerStatuses[i]
+		restart := s.State.Terminated != nil && converter.ShouldRestart(pod, int(s.State.Terminated.ExitCode))
+		p.setContainerRestartStatus(key, pod, s, restart)
+	}
+}
+
+func (p podman) setContainerRestartStatus(key string, pod *corev1.Pod, s *corev1.ContainerStatus, restart bool) {
+	restarts, delay := p.restarts.get(key, strings.TrimPrefix(s.ContainerID, converter.ContainerIDPrefix))
+	s.RestartCount = restarts
+	if !restart || delay == 0 {
+		return
+	}
+
+	s.LastTerminationState = s.State
+	s.State = corev1.ContainerState{
+		Waiting: &corev1.ContainerStateWaiting{
+			Reason:  "CrashLoopBackOff",
+			Message: fmt.Sprintf("back-off %s restarting failed container=%s pod=%s_%s(%s)", delay, s.Name, pod.Name, pod.Namespace, pod.UID),
+		},
+	}
+	s.Ready = false
+}
+
+// exited returns true if container is not running anymore
+func exited(c converter.PodmanContainer) bool {
+	return c.State.Status == "exited" || c.State.Status == "stopped"
+}
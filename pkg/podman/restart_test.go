@@ -0,0 +1,73 @@
+package podman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartTrackerBackOff(t *testing.T) {
+	tests := []struct {
+		name     string
+		restarts int
+		want     time.Duration
+	}{
+		{"first", 1, initialBackOff},
+		{"second", 2, 2 * initialBackOff},
+		{"third", 3, 4 * initialBackOff},
+		{"limit", 10, maxBackOff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRestartTracker()
+			var got time.Duration
+			for i := 0; i < tt.restarts; i++ {
+				got = r.next("ns-pod", "id")
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			restarts, delay := r.get("ns-pod", "id")
+			if restarts != int32(tt.restarts) || delay != tt.want {
+				t.Errorf("got %v %v, want %v %v", restarts, delay, tt.restarts, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartTrackerReady(t *testing.T) {
+	started := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	finished := started.Add(time.Minute)
+	tests := []struct {
+		name     string
+		restarts int
+		finished time.Time
+		now      time.Time
+		want     bool
+	}{
+		{"never restarted", 0, finished, finished, true},
+		{"back-off", 1, finished, finished.Add(initialBackOff - time.Second), false},
+		{"back-off expired", 1, finished, finished.Add(initialBackOff), true},
+		{"long back-off", 3, finished, finished.Add(2 * initialBackOff), false},
+		{"reset after long run", 3, started.Add(backOffReset + time.Second), started.Add(backOffReset + time.Second), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRestartTracker()
+			for i := 0; i < tt.restarts; i++ {
+				r.next("ns-pod", "id")
+			}
+			if got := r.ready("ns-pod", "id", started, tt.finished, tt.now); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartTrackerRemove(t *testing.T) {
+	r := newRestartTracker()
+	r.next("ns-pod", "id")
+	r.remove("ns-pod")
+	if restarts, delay := r.get("ns-pod", "id"); restarts != 0 || delay != 0 {
+		t.Errorf("got %v %v, want 0 0", restarts, delay)
+	}
+}
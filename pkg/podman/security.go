@@ -0,0 +1,50 @@
+package podman
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+	"github.com/virtual-kubelet/podman/pkg/util/errors"
+)
+
+// verifyNonRoot checks that container with runAsNonRoot and without
+// runAsUser does not run as root user of the image, same as kubelet
+func (p podman) verifyNonRoot(ctx context.Context, pod *corev1.Pod, c corev1.Container) error {
+	if !converter.RunAsNonRoot(pod, c) || converter.RunAsUser(pod, c) != nil {
+		return nil
+	}
+
+	p.c.Lock()
+	data, err := iopodman.InspectImage().Call(ctx, &p.c.Connection, c.Image)
+	p.c.Unlock()
+	if err != nil {
+		return errors.VKError(err)
+	}
+	var image struct {
+		Config struct {
+			User string `json:"User"`
+		} `json:"Config"`
+	}
+	err = json.Unmarshal([]byte(data), &image)
+	if err != nil {
+		return err
+	}
+
+	user := strings.Split(image.Config.User, ":")[0]
+	switch user {
+	case "", "0", "root":
+		return fmt.Errorf("container %s has runAsNonRoot and image will run as root", c.Name)
+	}
+	for _, r := range user {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("container %s has runAsNonRoot and image has non-numeric user (%s), cannot verify user is non-root", c.Name, user)
+		}
+	}
+	return nil
+}
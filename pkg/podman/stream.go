@@ -0,0 +1,232 @@
+package podman
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/varlink/go/varlink"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+)
+
+// streamDest is destination of the multiplexed stream frame, as defined by
+// podman virtwriter protocol
+type streamDest byte
+
+const (
+	toStdout streamDest = iota
+	toStdin
+	toStderr
+	terminalResize
+	quit
+	hangUpFromClient
+)
+
+// streamHeaderLen is length of the frame header. First byte is destination,
+// last four bytes are big-endian encoded length of the frame
+const streamHeaderLen = 8
+
+// stream is varlink connection upgraded by podman into the multiplexed
+// container stdio stream. It is used by exec
+type stream struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+// upgrade calls varlink method with upgrade flag on the dedicated connection.
+// varlink library does not expose connection after upgrade, so call
+// is sent directly on the socket
+func (p podman) upgrade(ctx context.Context, method string, parameters interface{}) (*stream, error) {
+	network, address, err := splitSocket(p.socket)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	call := struct {
+		Method     string      `json:"method"`
+		Parameters interface{} `json:"parameters,omitempty"`
+		Upgrade    bool        `json:"upgrade"`
+	}{
+		Method:     method,
+		Parameters: parameters,
+		Upgrade:    true,
+	}
+	b, err := json.Marshal(call)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := conn.Write(append(b, 0)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s := &stream{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+	out, err := s.reader.ReadBytes(0)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var reply struct {
+		Parameters *json.RawMessage `json:"parameters"`
+		Error      string           `json:"error"`
+	}
+	if err := json.Unmarshal(out[:len(out)-1], &reply); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if reply.Error != "" {
+		conn.Close()
+		return nil, iopodman.Dispatch_Error(&varlink.Error{
+			Name:       reply.Error,
+			Parameters: reply.Parameters,
+		})
+	}
+
+	return s, nil
+}
+
+// splitSocket splits varlink address into network and address
+func splitSocket(socket string) (string, string, error) {
+	words := strings.SplitN(socket, ":", 2)
+	if len(words) != 2 {
+		return "", "", fmt.Errorf("protocol missing in socket %s", socket)
+	}
+	// parameters after ';' are ignored same as in varlink
+	address := strings.SplitN(words[1], ";", 2)[0]
+	return words[0], address, nil
+}
+
+// write sends single frame to the given destination
+func (s *stream) write(dest streamDest, data []byte) error {
+	header := make([]byte, streamHeaderLen)
+	header[0] = byte(dest)
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.conn.Write(append(header, data...))
+	return err
+}
+
+// resize sends terminal resize event. Podman decodes it as JSON encoded
+// remotecommand.TerminalSize
+func (s *stream) resize(size api.TermSize) error {
+	data, err := json.Marshal(remotecommand.TerminalSize{
+		Width:  size.Width,
+		Height: size.Height,
+	})
+	if err != nil {
+		return err
+	}
+	return s.write(terminalResize, data)
+}
+
+// Close closes the upgraded connection
+func (s *stream) Close() error {
+	return s.conn.Close()
+}
+
+// run copies stdin and resize events into the stream and stream output into
+// stdout and stderr, until podman hangs up. It returns payload of the quit
+// frame, which is exit code for exec. Stream is closed when context is
+// cancelled
+func (s *stream) run(ctx context.Context, attach api.AttachIO) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		s.Close()
+	}()
+
+	if stdin := attach.Stdin(); stdin != nil {
+		go func() {
+			io.Copy(streamWriter{s: s, dest: toStdin}, stdin) //nolint:errcheck
+		}()
+	}
+
+	if resize := attach.Resize(); resize != nil {
+		go func() {
+			for {
+				select {
+				case size := <-resize:
+					if err := s.resize(size); err != nil {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	var stdout, stderr io.Writer = ioutil.Discard, ioutil.Discard
+	if attach.Stdout() != nil {
+		stdout = attach.Stdout()
+	}
+	if attach.Stderr() != nil {
+		stderr = attach.Stderr()
+	}
+
+	header := make([]byte, streamHeaderLen)
+	for {
+		if _, err := io.ReadFull(s.reader, header); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+
+		switch streamDest(header[0]) {
+		case toStdout:
+			if _, err := io.CopyN(stdout, s.reader, size); err != nil {
+				return nil, err
+			}
+		case toStderr:
+			if _, err := io.CopyN(stderr, s.reader, size); err != nil {
+				return nil, err
+			}
+		case quit:
+			data := make([]byte, size)
+			if _, err := io.ReadFull(s.reader, data); err != nil {
+				return nil, err
+			}
+			return data, nil
+		default:
+			return nil, fmt.Errorf("unknown stream destination %d", header[0])
+		}
+	}
+}
+
+// streamWriter writes data as frames to the given destination
+type streamWriter struct {
+	s    *stream
+	dest streamDest
+}
+
+func (w streamWriter) Write(b []byte) (int, error) {
+	if err := w.s.write(w.dest, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
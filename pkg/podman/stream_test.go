@@ -0,0 +1,199 @@
+package podman
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+)
+
+// readFrame reads single frame sent to the stream
+func readFrame(t *testing.T, r io.Reader) (streamDest, []byte) {
+	header := make([]byte, streamHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatalf("error reading frame header: %v", err)
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[4:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		t.Fatalf("error reading frame data: %v", err)
+	}
+	return streamDest(header[0]), data
+}
+
+// frame encodes single frame of the stream
+func frame(dest streamDest, data string) []byte {
+	header := make([]byte, streamHeaderLen)
+	header[0] = byte(dest)
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+// testAttach is attach without stdin and resize events
+type testAttach struct {
+	stdout, stderr bytes.Buffer
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func (a *testAttach) Stdin() io.Reader            { return nil }
+func (a *testAttach) Stdout() io.WriteCloser      { return nopCloser{&a.stdout} }
+func (a *testAttach) Stderr() io.WriteCloser      { return nopCloser{&a.stderr} }
+func (a *testAttach) TTY() bool                   { return false }
+func (a *testAttach) Resize() <-chan api.TermSize { return nil }
+
+func TestStreamRun(t *testing.T) {
+	exitCode := make([]byte, 4)
+	binary.BigEndian.PutUint32(exitCode, 3)
+	tests := []struct {
+		name    string
+		frames  [][]byte
+		stdout  string
+		stderr  string
+		quit    string
+		wantErr bool
+	}{
+		{
+			name:   "exec",
+			frames: [][]byte{frame(toStdout, "out"), frame(toStderr, "err"), frame(toStdout, "put"), frame(quit, string(exitCode))},
+			stdout: "output",
+			stderr: "err",
+			quit:   string(exitCode),
+		},
+		{
+			name:   "hang up",
+			frames: [][]byte{frame(toStdout, "hello\n"), frame(quit, "HANG-UP")},
+			stdout: "hello\n",
+			quit:   "HANG-UP",
+		},
+		{
+			name:    "unknown destination",
+			frames:  [][]byte{frame(toStdin, "in")},
+			wantErr: true,
+		},
+		{
+			name:    "closed without quit",
+			frames:  [][]byte{frame(toStdout, "out")},
+			stdout:  "out",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			s := &stream{conn: client, reader: bufio.NewReader(client)}
+			go func() {
+				for _, f := range tt.frames {
+					if _, err := server.Write(f); err != nil {
+						return
+					}
+				}
+				server.Close()
+			}()
+
+			attach := &testAttach{}
+			data, err := s.run(context.Background(), attach)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if string(data) != tt.quit {
+				t.Errorf("got quit %q, want %q", data, tt.quit)
+			}
+			if attach.stdout.String() != tt.stdout {
+				t.Errorf("got stdout %q, want %q", attach.stdout.String(), tt.stdout)
+			}
+			if attach.stderr.String() != tt.stderr {
+				t.Errorf("got stderr %q, want %q", attach.stderr.String(), tt.stderr)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"success", []byte{0, 0, 0, 0}, 0},
+		{"failure", []byte{0, 0, 0, 1}, 1},
+		{"signal", []byte{0, 0, 0, 137}, 137},
+		{"empty", nil, 0},
+		{"short", []byte{1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.data); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		dest streamDest
+		data string
+	}{
+		{"stdin", toStdin, "ls -l\n"},
+		{"empty", toStdin, ""},
+		{"hang up", hangUpFromClient, "HANG-UP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+			s := &stream{conn: client}
+
+			go s.write(tt.dest, []byte(tt.data)) //nolint:errcheck
+			dest, data := readFrame(t, server)
+			if dest != tt.dest {
+				t.Errorf("got destination %d, want %d", dest, tt.dest)
+			}
+			if string(data) != tt.data {
+				t.Errorf("got data %q, want %q", data, tt.data)
+			}
+		})
+	}
+}
+
+func TestStreamResize(t *testing.T) {
+	tests := []struct {
+		name string
+		size api.TermSize
+		want string
+	}{
+		{"terminal", api.TermSize{Width: 80, Height: 24}, `{"Width":80,"Height":24}`},
+		{"zero", api.TermSize{}, `{"Width":0,"Height":0}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+			s := &stream{conn: client}
+
+			go s.resize(tt.size) //nolint:errcheck
+			dest, data := readFrame(t, server)
+			if dest != terminalResize {
+				t.Errorf("got destination %d, want %d", dest, terminalResize)
+			}
+			if string(data) != tt.want {
+				t.Errorf("got data %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+	"github.com/virtual-kubelet/podman/pkg/util/errors"
+	"github.com/virtual-kubelet/podman/pkg/volume"
+)
+
+const (
+	// configMapPlugin is directory name of configMap volumes, same as used
+	// by kubelet
+	configMapPlugin = "kubernetes.io~configmap"
+	// secretPlugin is directory name of secret volumes, same as used by
+	// kubelet
+	secretPlugin = "kubernetes.io~secret"
+	// emptyDirPlugin is directory name of memory backed emptyDir volumes,
+	// same as used by kubelet
+	emptyDirPlugin = "kubernetes.io~empty-dir"
+	// podLabel is label of podman volumes with the pod key
+	podLabel = "io.kubernetes.pod.name"
+)
+
+// setupVolumes prepares pod volumes on the node. It returns host source of
+// each volume by volume name, which is mounted into the containers
+func (p podman) setupVolumes(ctx context.Context, pod *corev1.Pod) (map[string]string, error) {
+	sources := map[string]string{}
+	for _, v := range pod.Spec.Volumes {
+		switch {
+		case v.HostPath != nil:
+			// Create hostPath volumes if does not exist
+			var hostPathType corev1.HostPathType
+			if v.HostPath.Type != nil {
+				hostPathType = *v.HostPath.Type
+			}
+			switch hostPathType {
+			case corev1.HostPathDirectoryOrCreate:
+				err := os.MkdirAll(v.HostPath.Path, os.FileMode(0755))
+				if err != nil {
+					return nil, err
+				}
+			case corev1.HostPathDirectory:
+				if _, err := os.Stat(v.HostPath.Path); os.IsNotExist(err) {
+					return nil, fmt.Errorf("volume %s does not exist", v.Name)
+				}
+			default:
+				p.log.Debug("hostPath volume type %s is not supported", hostPathType)
+			}
+			sources[v.Name] = v.HostPath.Path
+		case v.ConfigMap != nil:
+			dir, err := p.writeConfigMapVolume(pod, v)
+			if err != nil {
+				return nil, err
+			}
+			sources[v.Name] = dir
+		case v.Secret != nil:
+			dir, err := p.writeSecretVolume(pod, v)
+			if err != nil {
+				return nil, err
+			}
+			sources[v.Name] = dir
+		case v.EmptyDir != nil:
+			dir, err := p.createEmptyDirVolume(ctx, pod, v)
+			if err != nil {
+				return nil, err
+			}
+			err = setOwnership(pod, dir, false)
+			if err != nil {
+				return nil, err
+			}
+			sources[v.Name] = dir
+		default:
+			p.log.Debug("volume provider %s is not supported", v.String())
+		}
+	}
+	return sources, nil
+}
+
+// syncVolumes updates content of the pod volumes, which are backed by
+// kubernetes resources
+func (p podman) syncVolumes(pod *corev1.Pod) error {
+	for _, v := range pod.Spec.Volumes {
+		var err error
+		switch {
+		case v.ConfigMap != nil:
+			_, err = p.writeConfigMapVolume(pod, v)
+		case v.Secret != nil:
+			_, err = p.writeSecretVolume(pod, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeVolumes removes all pod volumes from the node
+func (p podman) removeVolumes(ctx context.Context, pod *corev1.Pod) error {
+	podDir, err := p.podDir(pod)
+	if err != nil {
+		return err
+	}
+	key := converter.BuildKey(pod)
+	var names []string
+	for _, v := range pod.Spec.Volumes {
+		var err error
+		switch {
+		case v.Secret != nil:
+			err = volume.UnmountTmpfs(filepath.Join(podDir, "volumes", secretPlugin, v.Name))
+		case v.EmptyDir != nil && v.EmptyDir.Medium == corev1.StorageMediumMemory:
+			err = volume.UnmountTmpfs(filepath.Join(podDir, "volumes", emptyDirPlugin, v.Name))
+		case v.EmptyDir != nil:
+			names = append(names, converter.BuildVolumeName(key, v.Name))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range names {
+		p.c.Lock()
+		_, err := iopodman.VolumeRemove().Call(ctx, &p.c.Connection, iopodman.VolumeRemoveOpts{
+			Volumes: []string{name},
+			Force:   true,
+		})
+		p.c.Unlock()
+		if _, ok := err.(*iopodman.VolumeNotFound); err != nil && !ok {
+			return errors.VKError(err)
+		}
+	}
+	return os.RemoveAll(podDir)
+}
+
+// createEmptyDirVolume creates emptyDir volume shared by the pod
+// containers and returns its node path. Memory medium is backed by tmpfs
+// limited by sizeLimit, default medium by podman volume
+func (p podman) createEmptyDirVolume(ctx context.Context, pod *corev1.Pod, v corev1.Volume) (string, error) {
+	if v.EmptyDir.Medium == corev1.StorageMediumMemory {
+		var size int64
+		if v.EmptyDir.SizeLimit != nil {
+			size = v.EmptyDir.SizeLimit.Value()
+		}
+		dir, err := p.volumeDir(pod, emptyDirPlugin, v.Name)
+		if err != nil {
+			return "", err
+		}
+		return dir, volume.MountTmpfs(dir, size)
+	}
+	if v.EmptyDir.SizeLimit != nil {
+		p.log.Debug("sizeLimit of emptyDir volume ", v.Name, " is enforced only for memory medium")
+	}
+
+	key := converter.BuildKey(pod)
+	name := converter.BuildVolumeName(key, v.Name)
+	mountPoint, err := p.volumeMountPoint(ctx, name)
+	if err != nil || mountPoint != "" {
+		return mountPoint, err
+	}
+
+	p.c.Lock()
+	_, err = iopodman.VolumeCreate().Call(ctx, &p.c.Connection, iopodman.VolumeCreateOpts{
+		VolumeName: name,
+		Labels:     map[string]string{podLabel: key},
+	})
+	p.c.Unlock()
+	if err != nil {
+		return "", errors.VKError(err)
+	}
+	mountPoint, err = p.volumeMountPoint(ctx, name)
+	if err == nil && mountPoint == "" {
+		err = fmt.Errorf("volume %s has no mount point", name)
+	}
+	return mountPoint, err
+}
+
+// volumeMountPoint returns node path of the podman volume. Empty string is
+// returned if volume does not exist
+func (p podman) volumeMountPoint(ctx context.Context, name string) (string, error) {
+	p.c.Lock()
+	volumes, err := iopodman.GetVolumes().Call(ctx, &p.c.Connection, []string{name}, false)
+	p.c.Unlock()
+	if _, ok := err.(*iopodman.VolumeNotFound); ok {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.VKError(err)
+	}
+	for _, v := range volumes {
+		if v.Name == name {
+			return v.MountPoint, nil
+		}
+	}
+	return "", nil
+}
+
+// writeConfigMapVolume writes configMap keys into the pod volume directory
+// and returns the directory
+func (p podman) writeConfigMapVolume(pod *corev1.Pod, v corev1.Volume) (string, error) {
+	if p.resourceManager == nil {
+		return "", fmt.Errorf("configMap volume %s requires resource manager", v.Name)
+	}
+
+	source := v.ConfigMap
+	optional := source.Optional != nil && *source.Optional
+	configMap, err := p.resourceManager.GetConfigMap(source.Name, pod.Namespace)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) || !optional {
+			return "", err
+		}
+		configMap = &corev1.ConfigMap{}
+	}
+
+	payload, err := volume.ConfigMapPayload(source, configMap)
+	if err != nil {
+		return "", err
+	}
+	if fsGroup(pod) != nil {
+		volume.GroupReadable(payload)
+	}
+	dir, err := p.volumeDir(pod, configMapPlugin, v.Name)
+	if err != nil {
+		return "", err
+	}
+	err = volume.Write(dir, payload)
+	if err != nil {
+		return "", err
+	}
+	return dir, setOwnership(pod, dir, true)
+}
+
+// writeSecretVolume writes secret keys into the pod volume directory backed
+// by tmpfs and returns the directory
+func (p podman) writeSecretVolume(pod *corev1.Pod, v corev1.Volume) (string, error) {
+	if p.resourceManager == nil {
+		return "", fmt.Errorf("secret volume %s requires resource manager", v.Name)
+	}
+
+	source := v.Secret
+	optional := source.Optional != nil && *source.Optional
+	secret, err := p.resourceManager.GetSecret(source.SecretName, pod.Namespace)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) || !optional {
+			return "", err
+		}
+		secret = &corev1.Secret{}
+	}
+
+	payload, err := volume.SecretPayload(source, secret)
+	if err != nil {
+		return "", err
+	}
+	if fsGroup(pod) != nil {
+		volume.GroupReadable(payload)
+	}
+	// secrets must never be written to the disk
+	dir, err := p.volumeDir(pod, secretPlugin, v.Name)
+	if err != nil {
+		return "", err
+	}
+	err = volume.MountTmpfs(dir, 0)
+	if err != nil {
+		return "", err
+	}
+	err = volume.Write(dir, payload)
+	if err != nil {
+		return "", err
+	}
+	return dir, setOwnership(pod, dir, true)
+}
+
+// fsGroup returns group owning the pod volumes or nil
+func fsGroup(pod *corev1.Pod) *int64 {
+	if pod.Spec.SecurityContext == nil {
+		return nil
+	}
+	return pod.Spec.SecurityContext.FSGroup
+}
+
+// setOwnership applies pod fsGroup to the volume directory
+func setOwnership(pod *corev1.Pod, dir string, readOnly bool) error {
+	gid := fsGroup(pod)
+	if gid == nil {
+		return nil
+	}
+	return volume.SetOwnership(dir, *gid, readOnly)
+}
+
+// podDir returns node directory with the pod state. Pod without UID has no
+// directory, as the path would be the parent of all pod directories
+func (p podman) podDir(pod *corev1.Pod) (string, error) {
+	if pod.UID == "" {
+		return "", fmt.Errorf("pod %s/%s has no UID", pod.Namespace, pod.Name)
+	}
+	return filepath.Join(p.stateDir, "pods", string(pod.UID)), nil
+}
+
+// volumeDir returns node directory of the pod volume
+func (p podman) volumeDir(pod *corev1.Pod, plugin, name string) (string, error) {
+	dir, err := p.podDir(pod)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "volumes", plugin, name), nil
+}
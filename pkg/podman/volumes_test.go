@@ -0,0 +1,35 @@
+package podman
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPodDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		uid     string
+		want    string
+		wantErr bool
+	}{
+		{"uid", "1234", "/var/lib/vkubelet/pods/1234", false},
+		{"no uid", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := podman{stateDir: "/var/lib/vkubelet"}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pod"}}
+			pod.UID = types.UID(tt.uid)
+			got, err := p.podDir(pod)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
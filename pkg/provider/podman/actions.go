@@ -8,6 +8,8 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
 )
 
 const (
@@ -20,6 +22,9 @@ const (
 // UpdatePod accepts a Pod definition and updates its reference.
 func (p *PodmanV0Provider) UpdatePod(ctx context.Context, pod *v1.Pod) error {
 	log.G(ctx).Infof("receive UpdatePod %q", pod.Name)
+	key := converter.BuildKey(pod)
+	p.operations.begin(key)
+	defer p.operations.end(key)
 	err := p.c.Update(ctx, pod)
 	if err != nil {
 		return err
@@ -47,6 +52,7 @@ func (p *PodmanV0Provider) ConfigureNode(ctx context.Context, n *v1.Node) {
 	n.Status.NodeInfo.OperatingSystem = os
 	n.Status.NodeInfo.Architecture = "amd64"
 	n.ObjectMeta.Labels["alpha.service-controller.kubernetes.io/exclude-balancer"] = "true"
+	p.admission.setNode(n)
 }
 
 // Capacity returns a resource list containing the capacity limits.
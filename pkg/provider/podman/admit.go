@@ -0,0 +1,294 @@
+package podman
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	podresource "k8s.io/kubernetes/pkg/api/v1/resource"
+	v1helper "k8s.io/kubernetes/pkg/apis/core/v1/helper"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+)
+
+const (
+	// nodeSelectorPredicate is reason of the pod rejected by node selector
+	// or node affinity, same as used by kubelet
+	nodeSelectorPredicate = "MatchNodeSelector"
+	// hostPortsPredicate is reason of the pod rejected by host port
+	// conflict, same as used by kubelet
+	hostPortsPredicate = "PodFitsHostPorts"
+	// unsupportedSecurityContext is reason of the pod rejected by security
+	// context settings podman can't honour
+	unsupportedSecurityContext = "UnsupportedSecurityContext"
+)
+
+// admittedPod is resources used by the admitted pod
+type admittedPod struct {
+	requests v1.ResourceList
+	ports    []hostPort
+}
+
+// hostPort is host port published by the pod
+type hostPort struct {
+	ip       string
+	protocol v1.Protocol
+	port     int32
+}
+
+// conflicts returns true if ports can't be published at the same time
+func (h hostPort) conflicts(other hostPort) bool {
+	if h.port != other.port || h.protocol != other.protocol {
+		return false
+	}
+	return h.ip == other.ip || isAnyIP(h.ip) || isAnyIP(other.ip)
+}
+
+func isAnyIP(ip string) bool {
+	return ip == "" || ip == "0.0.0.0" || ip == "::"
+}
+
+// admission tracks resource requests and host ports of the pods admitted
+// on the node
+type admission struct {
+	sync.Mutex
+	pods map[string]admittedPod
+	node *v1.Node
+	// loaded is closed once pods running on the node are admitted
+	loaded chan struct{}
+}
+
+func newAdmission() *admission {
+	return &admission{
+		pods:   map[string]admittedPod{},
+		loaded: make(chan struct{}),
+	}
+}
+
+// waitLoaded waits until pods running on the node are admitted, so new pods
+// are checked against their requests
+func (a *admission) waitLoaded(ctx context.Context) error {
+	select {
+	case <-a.loaded:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// setNode sets node used to match pod node selector and affinity until
+// the node is known to the API server
+func (a *admission) setNode(node *v1.Node) {
+	a.Lock()
+	defer a.Unlock()
+	a.node = node.DeepCopy()
+}
+
+// admit checks pod against node allocatable, node selector, host ports in
+// use and security context supported by podman. Node is the node object of
+// the API server, node set by setNode is used if it is nil. Admitted pod
+// resources are tracked until the pod is released. It returns kubelet
+// failure reason and message if pod is rejected
+func (a *admission) admit(pod *v1.Pod, node *v1.Node, allocatable v1.ResourceList) (string, string) {
+	a.Lock()
+	defer a.Unlock()
+
+	key := converter.BuildKey(pod)
+	if _, ok := a.pods[key]; ok {
+		return "", ""
+	}
+
+	if err := converter.ValidateSecurityContext(pod); err != nil {
+		return unsupportedSecurityContext, err.Error()
+	}
+
+	if node == nil {
+		node = a.node
+	}
+	if node != nil && !podMatchesNode(pod, node) {
+		return nodeSelectorPredicate, fmt.Sprintf("Predicate %s failed", nodeSelectorPredicate)
+	}
+
+	ports := podHostPorts(pod)
+	for _, admitted := range a.pods {
+		for _, used := range admitted.ports {
+			for _, port := range ports {
+				if port.conflicts(used) {
+					return hostPortsPredicate, fmt.Sprintf("Predicate %s failed", hostPortsPredicate)
+				}
+			}
+		}
+	}
+
+	requests := podRequests(pod)
+	used := v1.ResourceList{}
+	for _, admitted := range a.pods {
+		for name, quantity := range admitted.requests {
+			value := used[name]
+			value.Add(quantity)
+			used[name] = value
+		}
+	}
+	for _, name := range []v1.ResourceName{v1.ResourcePods, v1.ResourceCPU, v1.ResourceMemory} {
+		capacity, ok := allocatable[name]
+		if !ok {
+			continue
+		}
+		requested := requests[name]
+		total := used[name]
+		total.Add(requested)
+		if total.Cmp(capacity) > 0 {
+			usedValue := used[name]
+			return fmt.Sprintf("OutOf%s", name), fmt.Sprintf(
+				"Node didn't have enough resource: %s, requested: %d, used: %d, capacity: %d",
+				name, quantityValue(name, requested), quantityValue(name, usedValue), quantityValue(name, capacity))
+		}
+	}
+
+	a.pods[key] = admittedPod{requests: requests, ports: ports}
+	return "", ""
+}
+
+// admitted returns true if the pod was admitted and not released yet
+func (a *admission) admitted(pod *v1.Pod) bool {
+	a.Lock()
+	defer a.Unlock()
+	_, ok := a.pods[converter.BuildKey(pod)]
+	return ok
+}
+
+// release stops tracking pod requests
+func (a *admission) release(pod *v1.Pod) {
+	a.Lock()
+	defer a.Unlock()
+	delete(a.pods, converter.BuildKey(pod))
+}
+
+// admitPod rejects pod, which does not fit the node. Rejected pod is
+// reported as failed with kubelet reason and warning event. It returns false
+// if pod is rejected
+func (p *PodmanV0Provider) admitPod(ctx context.Context, pod *v1.Pod) bool {
+	reason, message := p.admission.admit(pod, p.liveNode(), p.capacity())
+	if reason == "" {
+		return true
+	}
+
+	log.G(ctx).Infof("pod %s/%s rejected: %s", pod.Namespace, pod.Name, message)
+	pod.Status.Phase = v1.PodFailed
+	pod.Status.Reason = reason
+	pod.Status.Message = message
+	if p.eventRecorder != nil {
+		p.eventRecorder.Event(pod, v1.EventTypeWarning, reason, message)
+	}
+	p.notifier(pod)
+	return false
+}
+
+// liveNode returns the node object of the API server, so node labels set
+// through the API are matched. It returns nil if the node is not known yet
+func (p *PodmanV0Provider) liveNode() *v1.Node {
+	if p.resourceManager == nil {
+		return nil
+	}
+	node, err := p.resourceManager.GetNode(p.nodeName)
+	if err != nil {
+		return nil
+	}
+	return node
+}
+
+// loadAdmittedPods admits pods already running on the node, the same way
+// kubelet does on restart. It waits until the node and its pods are synced
+// from the API server. Pods are admitted in order of creation, pods which
+// don't fit the node anymore are rejected and removed. New pods are not
+// admitted until it is done
+func (p *PodmanV0Provider) loadAdmittedPods(ctx context.Context) {
+	defer close(p.admission.loaded)
+	if p.resourceManager != nil && !p.resourceManager.WaitForSync(ctx) {
+		return
+	}
+
+	// pods not created by the provider are neither counted nor removed
+	pods, err := p.c.ListManaged(ctx, p.nodeName)
+	if err != nil {
+		log.G(ctx).Warnf("error while loading admitted pods: %v", err)
+		return
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].CreationTimestamp.Before(&pods[j].CreationTimestamp)
+	})
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if p.admitPod(ctx, pod) {
+			continue
+		}
+		err = p.c.Delete(ctx, pod)
+		if err != nil {
+			log.G(ctx).Warnf("error while removing rejected pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+}
+
+// podRequests returns resources requested by the pod, including the pod
+// itself
+func podRequests(pod *v1.Pod) v1.ResourceList {
+	requests, _ := podresource.PodRequestsAndLimits(pod)
+	requests[v1.ResourcePods] = *resource.NewQuantity(1, resource.DecimalSI)
+	return requests
+}
+
+// podHostPorts returns host ports published by the pod containers
+func podHostPorts(pod *v1.Pod) []hostPort {
+	var ports []hostPort
+	containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		for _, p := range c.Ports {
+			if p.HostPort == 0 {
+				continue
+			}
+			protocol := p.Protocol
+			if protocol == "" {
+				protocol = v1.ProtocolTCP
+			}
+			ports = append(ports, hostPort{ip: p.HostIP, protocol: protocol, port: p.HostPort})
+		}
+	}
+	return ports
+}
+
+// podMatchesNode checks pod nodeSelector and required node affinity
+func podMatchesNode(pod *v1.Pod, node *v1.Node) bool {
+	nodeLabels := labels.Set(node.Labels)
+	if len(pod.Spec.NodeSelector) > 0 {
+		if !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(nodeLabels) {
+			return false
+		}
+	}
+
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil {
+		return true
+	}
+	required := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if required == nil {
+		return true
+	}
+	nodeFields := fields.Set{"metadata.name": node.Name}
+	return v1helper.MatchNodeSelectorTerms(required.NodeSelectorTerms, nodeLabels, nodeFields)
+}
+
+// quantityValue returns quantity in units reported by kubelet
+func quantityValue(name v1.ResourceName, quantity resource.Quantity) int64 {
+	if name == v1.ResourceCPU {
+		return quantity.MilliValue()
+	}
+	return quantity.Value()
+}
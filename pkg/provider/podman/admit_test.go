@@ -0,0 +1,167 @@
+package podman
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPod(name string, cpu, memory string, ports ...v1.ContainerPort) *v1.Pod {
+	requests := v1.ResourceList{}
+	if cpu != "" {
+		requests[v1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		requests[v1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:      "app",
+				Resources: v1.ResourceRequirements{Requests: requests},
+				Ports:     ports,
+			}},
+		},
+	}
+}
+
+func TestHostPortConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b hostPort
+		want bool
+	}{
+		{"same", hostPort{"", v1.ProtocolTCP, 80}, hostPort{"", v1.ProtocolTCP, 80}, true},
+		{"other port", hostPort{"", v1.ProtocolTCP, 80}, hostPort{"", v1.ProtocolTCP, 81}, false},
+		{"other protocol", hostPort{"", v1.ProtocolTCP, 53}, hostPort{"", v1.ProtocolUDP, 53}, false},
+		{"other ip", hostPort{"10.0.0.1", v1.ProtocolTCP, 80}, hostPort{"10.0.0.2", v1.ProtocolTCP, 80}, false},
+		{"any ipv4", hostPort{"0.0.0.0", v1.ProtocolTCP, 80}, hostPort{"10.0.0.2", v1.ProtocolTCP, 80}, true},
+		{"any ipv6", hostPort{"10.0.0.1", v1.ProtocolTCP, 80}, hostPort{"::", v1.ProtocolTCP, 80}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.conflicts(tt.b); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if got := tt.b.conflicts(tt.a); got != tt.want {
+				t.Errorf("got reversed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	allocatable := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("1"),
+		v1.ResourceMemory: resource.MustParse("1Gi"),
+		v1.ResourcePods:   resource.MustParse("3"),
+	}
+	web := v1.ContainerPort{ContainerPort: 80, HostPort: 8080}
+	nonRoot := true
+	root := int64(0)
+
+	tests := []struct {
+		name       string
+		admitted   []*v1.Pod
+		pod        *v1.Pod
+		wantReason string
+	}{
+		{
+			name: "fits",
+			pod:  testPod("a", "500m", "512Mi"),
+		},
+		{
+			name:     "fits exactly",
+			admitted: []*v1.Pod{testPod("a", "500m", "512Mi")},
+			pod:      testPod("b", "500m", "512Mi"),
+		},
+		{
+			name:       "out of cpu",
+			admitted:   []*v1.Pod{testPod("a", "600m", "")},
+			pod:        testPod("b", "500m", ""),
+			wantReason: "OutOfcpu",
+		},
+		{
+			name:       "out of memory",
+			admitted:   []*v1.Pod{testPod("a", "", "1Gi")},
+			pod:        testPod("b", "", "1"),
+			wantReason: "OutOfmemory",
+		},
+		{
+			name:       "out of pods",
+			admitted:   []*v1.Pod{testPod("a", "", ""), testPod("b", "", ""), testPod("c", "", "")},
+			pod:        testPod("d", "", ""),
+			wantReason: "OutOfpods",
+		},
+		{
+			name:       "host port in use",
+			admitted:   []*v1.Pod{testPod("a", "", "", web)},
+			pod:        testPod("b", "", "", web),
+			wantReason: hostPortsPredicate,
+		},
+		{
+			name:     "already admitted",
+			admitted: []*v1.Pod{testPod("a", "1", "", web)},
+			pod:      testPod("a", "1", "", web),
+		},
+		{
+			name: "runAsNonRoot with root user",
+			pod: func() *v1.Pod {
+				pod := testPod("a", "", "")
+				pod.Spec.SecurityContext = &v1.PodSecurityContext{RunAsNonRoot: &nonRoot, RunAsUser: &root}
+				return pod
+			}(),
+			wantReason: unsupportedSecurityContext,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdmission()
+			for _, pod := range tt.admitted {
+				if reason, message := a.admit(pod, nil, nil); reason != "" {
+					t.Fatalf("pod %s rejected: %s", pod.Name, message)
+				}
+			}
+			reason, _ := a.admit(tt.pod, nil, allocatable)
+			if reason != tt.wantReason {
+				t.Errorf("got reason %q, want %q", reason, tt.wantReason)
+			}
+			if admitted := a.admitted(tt.pod); admitted != (reason == "") {
+				t.Errorf("got admitted %v", admitted)
+			}
+		})
+	}
+}
+
+func TestAdmitNode(t *testing.T) {
+	local := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "edge", Labels: map[string]string{"type": "virtual-kubelet"}}}
+	live := local.DeepCopy()
+	live.Labels["zone"] = "garage"
+
+	tests := []struct {
+		name       string
+		live       *v1.Node
+		selector   map[string]string
+		wantReason string
+	}{
+		{"local labels", nil, map[string]string{"type": "virtual-kubelet"}, ""},
+		{"label set through API", live, map[string]string{"zone": "garage"}, ""},
+		{"label not known yet", nil, map[string]string{"zone": "garage"}, nodeSelectorPredicate},
+		{"label missing", live, map[string]string{"zone": "kitchen"}, nodeSelectorPredicate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdmission()
+			a.setNode(local)
+			pod := testPod("a", "", "")
+			pod.Spec.NodeSelector = tt.selector
+			reason, _ := a.admit(pod, tt.live, nil)
+			if reason != tt.wantReason {
+				t.Errorf("got reason %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -36,6 +36,15 @@ func loadConfig(providerConfig, nodeName string) (config PodmanConfig, err error
 		if config.Socket == "" {
 			config.Socket = defaultSocket
 		}
+		if config.StateDir == "" {
+			config.StateDir = defaultStateDir
+		}
+		if config.PullAuthFile == "" {
+			config.PullAuthFile = defaultPullAuthFile
+		}
+		if config.ResolvConf == "" {
+			config.ResolvConf = defaultResolvConf
+		}
 		if config.DaemonSetDisabled == "" {
 			config.DaemonSetDisabled = defaultDaemonSetDisabled
 		}
@@ -6,10 +6,21 @@ import (
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	v1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+	"github.com/virtual-kubelet/podman/pkg/util/errors"
 )
 
 // CreatePod accepts a Pod definition and stores it in memory.
 func (p *PodmanV0Provider) CreatePod(ctx context.Context, pod *v1.Pod) error {
+	key := converter.BuildKey(pod)
+	p.operations.begin(key)
+	defer p.operations.end(key)
+	return p.createPod(ctx, pod)
+}
+
+// createPod creates the pod, caller tracks the pod operation
+func (p *PodmanV0Provider) createPod(ctx context.Context, pod *v1.Pod) error {
 	// if DS is disabled, fail eary
 	if p.config.DaemonSetDisabled == "true" {
 		for _, owner := range pod.OwnerReferences {
@@ -30,10 +41,23 @@ func (p *PodmanV0Provider) CreatePod(ctx context.Context, pod *v1.Pod) error {
 	}
 
 	log.G(ctx).Infof("receive CreatePod %q", pod.Name)
-	err := p.c.Create(ctx, pod)
+	err := p.admission.waitLoaded(ctx)
 	if err != nil {
 		return err
 	}
+	// rejected pod is failed and reported by notifier, so it is not retried
+	if !p.admitPod(ctx, pod) {
+		return nil
+	}
+
+	err = p.c.Create(ctx, pod)
+	if err != nil {
+		// existing podman pod keeps its resources admitted
+		if !errors.IsAlreadyExists(err) {
+			p.admission.release(pod)
+		}
+		return err
+	}
 
 	pod, err = p.c.Get(ctx, pod)
 	if err != nil {
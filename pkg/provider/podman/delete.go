@@ -5,10 +5,16 @@ import (
 
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	v1 "k8s.io/api/core/v1"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
 )
 
 // DeletePod deletes the specified pod out of memory.
 func (p *PodmanV0Provider) DeletePod(ctx context.Context, pod *v1.Pod) (err error) {
 	log.G(ctx).Infof("receive DeletePod %s", pod.Namespace, pod.Name)
+	key := converter.BuildKey(pod)
+	p.operations.begin(key)
+	defer p.operations.end(key)
+	p.admission.release(pod)
 	return p.c.Delete(ctx, pod)
 }
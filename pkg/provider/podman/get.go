@@ -3,8 +3,6 @@ package podman
 import (
 	"context"
 	"io"
-	"io/ioutil"
-	"strings"
 
 	"github.com/virtual-kubelet/podman/pkg/converter"
 
@@ -27,17 +25,16 @@ func (p *PodmanV0Provider) GetPod(ctx context.Context, namespace, name string) (
 }
 
 // GetContainerLogs retrieves the logs of a container by name from the provider.
-// TODO: To implement
 func (p *PodmanV0Provider) GetContainerLogs(ctx context.Context, namespace, podName, containerName string, opts api.ContainerLogOpts) (io.ReadCloser, error) {
 	log.G(ctx).Infof("receive GetContainerLogs %q", podName)
-	return ioutil.NopCloser(strings.NewReader("")), nil
+	return p.c.GetContainerLogs(ctx, namespace, podName, containerName, opts)
 }
 
 // RunInContainer executes a command in a container in the pod, copying data
 // between in/out/err and the container's stdin/stdout/stderr.
 func (p *PodmanV0Provider) RunInContainer(ctx context.Context, namespace, name, container string, cmd []string, attach api.AttachIO) error {
-	log.G(context.TODO()).Infof("receive ExecInContainer %q", container)
-	return nil
+	log.G(ctx).Infof("receive ExecInContainer %q", container)
+	return p.c.Exec(ctx, namespace, name, container, cmd, attach)
 }
 
 // GetPodStatus returns the status of a pod by name that is "running".
@@ -2,11 +2,13 @@ package podman
 
 import (
 	"context"
+	"net"
 	"time"
 
 	"github.com/virtual-kubelet/podman/pkg/manager"
 	"github.com/virtual-kubelet/podman/pkg/podman"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
@@ -15,6 +17,9 @@ const (
 	defaultMemoryCapacity    = "2Gi"
 	defaultPodCapacity       = "10"
 	defaultSocket            = "unix:/run/podman/io.podman"
+	defaultStateDir          = "/var/lib/vkubelet"
+	defaultPullAuthFile      = "/run/containers/0/auth.json"
+	defaultResolvConf        = "/etc/resolv.conf"
 	defaultDaemonSetDisabled = "true"
 )
 
@@ -29,6 +34,13 @@ type PodmanV0Provider struct {
 	daemonEndpointPort int32
 	c                  podman.Podman
 	resourceManager    *manager.ResourceManager
+	admission          *admission
+	operations         *podOperations
+	eventRecorder      record.EventRecorder
+	// statusLocks serialize status updates of each pod
+	statusLocks *podLocks
+	// watching is 1 while podman events are watched
+	watching int32
 }
 
 // PodmanProvider is like PodmanV0Provider, but implements the PodNotifier interface
@@ -43,57 +55,125 @@ type PodmanConfig struct {
 	Pods   string `json:"pods,omitempty"`
 
 	Socket string `json:"socket,omitempty"`
+	// StateDir is node directory where pod volumes are stored
+	StateDir string `json:"stateDir,omitempty"`
+	// PidsLimit is default pids limit of the pod containers
+	PidsLimit int64 `json:"pidsLimit,omitempty"`
+	// NativeHealthchecks runs exec liveness probes as podman healthchecks,
+	// which is lighter on weak devices than probing from the provider
+	NativeHealthchecks bool `json:"nativeHealthchecks,omitempty"`
+	// StorageQuota enforces ephemeral-storage limits as container root
+	// filesystem size. Podman supports it only with overlay on xfs mounted
+	// with pquota, limits are not enforced otherwise
+	StorageQuota bool `json:"storageQuota,omitempty"`
+	// AuthFile is node registry credentials file, used when pod pull
+	// secrets do not match the image registry
+	AuthFile string `json:"authFile,omitempty"`
+	// PullAuthFile is auth file read by podman service on pulls, pull
+	// credentials of the image registry are handed to podman through it for
+	// the duration of the pull
+	PullAuthFile string `json:"pullAuthFile,omitempty"`
+	// ClusterDNS is cluster DNS server addresses used by ClusterFirst
+	// dnsPolicy. Pods use node resolver if it is not set
+	ClusterDNS []string `json:"clusterDNS,omitempty"`
+	// ResolvConf is node resolver configuration used by Default dnsPolicy
+	ResolvConf string `json:"resolvConf,omitempty"`
+	// ReconcileDryRun only reports orphaned podman pods and missing pods
+	// found by reconcile, they are not removed nor recreated
+	ReconcileDryRun bool `json:"reconcileDryRun,omitempty"`
 
 	DaemonSetDisabled string `json:"daemonSetDisabled,omitempty"`
 }
 
 // NewPodmanV0ProviderPodmanConfig creates a new PodmanV0Provider. podman legacy provider does not implement the new asynchronous podnotifier interface
-func NewPodmanV0ProviderPodmanConfig(config PodmanConfig, nodeName, operatingSystem string, resourceManager *manager.ResourceManager) (*PodmanV0Provider, error) {
-	client, err := podman.New(context.Background(), nil)
-	if err != nil {
-		return nil, err
+func NewPodmanV0ProviderPodmanConfig(config PodmanConfig, nodeName, operatingSystem string, internalIP string, daemonEndpointPort int32, clusterDomain string, resourceManager *manager.ResourceManager, eventRecorder record.EventRecorder) (*PodmanV0Provider, error) {
+	if internalIP == "" {
+		internalIP = hostIP()
 	}
-
 	provider := PodmanV0Provider{
-		nodeName:        nodeName,
-		operatingSystem: operatingSystem,
-		config:          config,
-		startTime:       time.Now(),
-		c:               client,
-		resourceManager: resourceManager,
+		nodeName:           nodeName,
+		operatingSystem:    operatingSystem,
+		internalIP:         internalIP,
+		daemonEndpointPort: daemonEndpointPort,
+		config:             config,
+		startTime:          time.Now(),
+		resourceManager:    resourceManager,
+		admission:          newAdmission(),
+		operations:         newPodOperations(),
+		statusLocks:        newPodLocks(),
+		eventRecorder:      eventRecorder,
 		// By default notifier is set to a function which is a no-op. In the event we've implemented the PodNotifier interface,
 		// it will be set, and then we'll call a real underlying implementation.
 		// This makes it easier in the sense we don't need to wrap each method.
 		notifier: func(pod *v1.Pod) {},
 	}
 
+	client, err := podman.New(context.Background(), &podman.Config{
+		Socket:             &config.Socket,
+		HostIP:             &internalIP,
+		StateDir:           &config.StateDir,
+		ResourceManager:    resourceManager,
+		Allocatable:        provider.capacity(),
+		PidsLimit:          config.PidsLimit,
+		NativeHealthchecks: config.NativeHealthchecks,
+		StorageQuota:       config.StorageQuota,
+		AuthFile:           &config.AuthFile,
+		PullAuthFile:       &config.PullAuthFile,
+		ClusterDomain:      clusterDomain,
+		ClusterDNS:         config.ClusterDNS,
+		ResolvConf:         &config.ResolvConf,
+		EventRecorder:      eventRecorder,
+	})
+	if err != nil {
+		return nil, err
+	}
+	provider.c = client
+	go provider.loadAdmittedPods(context.Background())
+
 	go provider.reconcile()
+	go provider.watch()
 	return &provider, nil
 }
 
+// hostIP returns first global unicast IPv4 address of the host. It is used
+// as node address when internal IP is not configured
+func hostIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if ok && ipNet.IP.IsGlobalUnicast() && ipNet.IP.To4() != nil {
+			return ipNet.IP.String()
+		}
+	}
+	return ""
+}
+
 // NewPodmanV0Provider creates a new PodmanV0Provider
-func NewPodmanV0Provider(providerConfig, nodeName, operatingSystem string, resourceManager *manager.ResourceManager) (*PodmanV0Provider, error) {
+func NewPodmanV0Provider(providerConfig, nodeName, operatingSystem string, internalIP string, daemonEndpointPort int32, clusterDomain string, resourceManager *manager.ResourceManager, eventRecorder record.EventRecorder) (*PodmanV0Provider, error) {
 	config, err := loadConfig(providerConfig, nodeName)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewPodmanV0ProviderPodmanConfig(config, nodeName, operatingSystem, resourceManager)
+	return NewPodmanV0ProviderPodmanConfig(config, nodeName, operatingSystem, internalIP, daemonEndpointPort, clusterDomain, resourceManager, eventRecorder)
 }
 
 // NewPodmanProviderPodmanConfig creates a new PodmanProvider with the given config
-func NewPodmanProviderPodmanConfig(config PodmanConfig, nodeName, operatingSystem string, resourceManager *manager.ResourceManager) (*PodmanProvider, error) {
-	p, err := NewPodmanV0ProviderPodmanConfig(config, nodeName, operatingSystem, resourceManager)
+func NewPodmanProviderPodmanConfig(config PodmanConfig, nodeName, operatingSystem string, internalIP string, daemonEndpointPort int32, clusterDomain string, resourceManager *manager.ResourceManager, eventRecorder record.EventRecorder) (*PodmanProvider, error) {
+	p, err := NewPodmanV0ProviderPodmanConfig(config, nodeName, operatingSystem, internalIP, daemonEndpointPort, clusterDomain, resourceManager, eventRecorder)
 
 	return &PodmanProvider{PodmanV0Provider: p}, err
 }
 
 // NewPodmanProvider creates a new PodmanProvider, which implements the PodNotifier interface
-func NewPodmanProvider(providerConfig, nodeName, operatingSystem string, resourceManager *manager.ResourceManager) (*PodmanProvider, error) {
+func NewPodmanProvider(providerConfig, nodeName, operatingSystem string, internalIP string, daemonEndpointPort int32, clusterDomain string, resourceManager *manager.ResourceManager, eventRecorder record.EventRecorder) (*PodmanProvider, error) {
 	config, err := loadConfig(providerConfig, nodeName)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewPodmanProviderPodmanConfig(config, nodeName, operatingSystem, resourceManager)
+	return NewPodmanProviderPodmanConfig(config, nodeName, operatingSystem, internalIP, daemonEndpointPort, clusterDomain, resourceManager, eventRecorder)
 }
@@ -2,31 +2,311 @@ package podman
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/virtual-kubelet/podman/pkg/converter"
+	"github.com/virtual-kubelet/podman/pkg/util/errors"
+)
+
+const (
+	// pollInterval is refresh interval of all pods while podman events are
+	// not available
+	pollInterval = 10 * time.Second
+	// resyncInterval is refresh interval of all pods while podman events
+	// are watched, it recovers from missed events
+	resyncInterval = 5 * time.Minute
+
+	// reasons of the reconcile events
+	orphanedPodReason = "OrphanedPod"
+	stalePodReason    = "StalePod"
+	missingPodReason  = "MissingPod"
 )
 
+// podOperations tracks pods being created or deleted, so reconcile does
+// not act on them in the meantime
+type podOperations struct {
+	sync.Mutex
+	pods map[string]int
+}
+
+func newPodOperations() *podOperations {
+	return &podOperations{
+		pods: map[string]int{},
+	}
+}
+
+func (o *podOperations) begin(key string) {
+	o.Lock()
+	defer o.Unlock()
+	o.pods[key]++
+}
+
+// tryBegin begins operation if no other operation of the pod is running
+func (o *podOperations) tryBegin(key string) bool {
+	o.Lock()
+	defer o.Unlock()
+	if o.pods[key] > 0 {
+		return false
+	}
+	o.pods[key]++
+	return true
+}
+
+func (o *podOperations) end(key string) {
+	o.Lock()
+	defer o.Unlock()
+	o.pods[key]--
+	if o.pods[key] <= 0 {
+		delete(o.pods, key)
+	}
+}
+
+// podLocks are mutexes of the pods, which are forgotten once unlocked
+type podLocks struct {
+	sync.Mutex
+	pods map[string]*podLock
+}
+
+type podLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newPodLocks() *podLocks {
+	return &podLocks{
+		pods: map[string]*podLock{},
+	}
+}
+
+func (l *podLocks) lock(key string) {
+	l.Lock()
+	pod := l.pods[key]
+	if pod == nil {
+		pod = &podLock{}
+		l.pods[key] = pod
+	}
+	pod.refs++
+	l.Unlock()
+	pod.Lock()
+}
+
+func (l *podLocks) unlock(key string) {
+	l.Lock()
+	defer l.Unlock()
+	pod := l.pods[key]
+	pod.refs--
+	if pod.refs == 0 {
+		delete(l.pods, key)
+	}
+	pod.Unlock()
+}
+
 func (p *PodmanV0Provider) reconcile() error {
 	for {
 		ctx := context.Background()
-		time.Sleep(10 * time.Second)
+		p.waitReconcile()
 		log.G(ctx).Infof("reconcile all pods status")
+		p.reconcilePods(ctx)
 		pods := p.resourceManager.GetPods()
 
-		if pods != nil {
-			for _, pod := range pods {
-				updatePod := pod.DeepCopy()
-				currentPod, err := p.c.Get(ctx, updatePod)
-				if err != nil {
-					log.G(ctx).Debugf("error while reconcile pod %s/%s", pod.Namespace, pod.Name)
-					continue
+		for _, pod := range pods {
+			p.updatePodStatus(ctx, pod)
+		}
+	}
+}
+
+// waitReconcile waits for the next refresh of all pods. Watch state is
+// checked every pollInterval, so polling starts soon after the event
+// stream fails
+func (p *PodmanV0Provider) waitReconcile() {
+	for waited := time.Duration(0); waited < resyncInterval; waited += pollInterval {
+		time.Sleep(pollInterval)
+		if atomic.LoadInt32(&p.watching) == 0 {
+			return
+		}
+	}
+}
+
+// watch refreshes status of the pods as soon as podman reports their
+// change. Event stream is reopened when it ends, pods are polled meanwhile
+func (p *PodmanV0Provider) watch() {
+	ctx := context.Background()
+	for {
+		keys, err := p.c.Watch(ctx)
+		if err != nil {
+			log.G(ctx).Warnf("error watching podman events, polling pods status: %v", err)
+			time.Sleep(pollInterval)
+			continue
+		}
+		atomic.StoreInt32(&p.watching, 1)
+		for key := range keys {
+			for _, pod := range p.resourceManager.GetPods() {
+				if converter.BuildKey(pod) == key {
+					p.updatePodStatus(ctx, pod)
 				}
-				if updatePod != nil {
-					updatePod.Status = currentPod.Status
-					p.notifier(updatePod)
+			}
+		}
+		atomic.StoreInt32(&p.watching, 0)
+		log.G(ctx).Warnf("podman event stream closed, polling pods status")
+		time.Sleep(pollInterval)
+	}
+}
+
+// updatePodStatus enforces pod restart policy and notifies its current
+// status
+func (p *PodmanV0Provider) updatePodStatus(ctx context.Context, pod *v1.Pod) {
+	// events and resync must not restart the same container twice, other
+	// pods are not blocked by a slow sync
+	key := converter.BuildKey(pod)
+	p.statusLocks.lock(key)
+	defer p.statusLocks.unlock(key)
+
+	updatePod := pod.DeepCopy()
+	// enforce restart policy before status is read
+	err := p.c.Sync(ctx, updatePod)
+	if err != nil {
+		log.G(ctx).Debugf("error while sync pod %s/%s", pod.Namespace, pod.Name)
+	}
+	currentPod, err := p.c.Get(ctx, updatePod)
+	if errors.IsPodNotFound(err) {
+		// create can take long, so it does not block status updates
+		go p.recreatePod(context.Background(), pod)
+		return
+	}
+	if err != nil {
+		log.G(ctx).Debugf("error while reconcile pod %s/%s", pod.Namespace, pod.Name)
+		return
+	}
+	// resources of terminated pods are not used anymore
+	if currentPod.Status.Phase == v1.PodSucceeded || currentPod.Status.Phase == v1.PodFailed {
+		p.admission.release(updatePod)
+	}
+	updatePod.Status = currentPod.Status
+	p.notifier(updatePod)
+}
+
+// reconcilePods removes podman pods of the node, which kubernetes pod was
+// deleted or replaced by a pod with another UID, and recreates admitted pods
+// missing in podman. Pods without the ownership label are never touched.
+// Nothing is done until the pod lister synced and orphans are kept while
+// the API server is unreachable, as the lister may miss pods then
+func (p *PodmanV0Provider) reconcilePods(ctx context.Context) {
+	if !p.resourceManager.HasSynced() {
+		log.G(ctx).Infof("pod lister not synced, skipping reconcile of podman pods")
+		return
+	}
+	healthErr := p.resourceManager.CheckHealth()
+	if healthErr != nil {
+		log.G(ctx).Warnf("API server unreachable, keeping orphaned podman pods: %v", healthErr)
+	}
+	managed, err := p.c.ListManaged(ctx, p.nodeName)
+	if err != nil {
+		log.G(ctx).Warnf("error while listing managed pods: %v", err)
+		return
+	}
+	pods := map[string]*v1.Pod{}
+	for _, pod := range p.resourceManager.GetPods() {
+		pods[converter.BuildKey(pod)] = pod
+	}
+
+	existing := map[string]bool{}
+	for _, m := range managed {
+		key := converter.BuildKey(m)
+		existing[key] = true
+		pod, ok := pods[key]
+		if ok && pod.UID == m.UID || !ok && healthErr != nil {
+			continue
+		}
+		if !p.operations.tryBegin(key) {
+			continue
+		}
+
+		if !ok {
+			message := fmt.Sprintf("Removing podman pod %s of deleted pod %s/%s", key, m.Namespace, m.Name)
+			if p.correct(ctx, p.nodeReference(), orphanedPodReason, message) {
+				p.admission.release(m)
+				err = p.c.Delete(ctx, m)
+			}
+		} else {
+			message := fmt.Sprintf("Replacing podman pod %s of previous pod with UID %s", key, m.UID)
+			if p.correct(ctx, pod, stalePodReason, message) {
+				p.admission.release(m)
+				err = p.c.Delete(ctx, m)
+				if err == nil {
+					err = p.createPod(ctx, pod.DeepCopy())
 				}
 			}
 		}
+		p.operations.end(key)
+		if err != nil {
+			log.G(ctx).Warnf("error while reconciling podman pod %s: %v", key, err)
+		}
+	}
+
+	for key, pod := range pods {
+		if !existing[key] {
+			p.recreatePod(ctx, pod)
+		}
+	}
+}
+
+// recreatePod creates admitted pod, which podman pod was removed outside of
+// the provider. Deleted and terminated pods are not recreated
+func (p *PodmanV0Provider) recreatePod(ctx context.Context, pod *v1.Pod) {
+	if pod.DeletionTimestamp != nil || !p.admission.admitted(pod) {
+		return
+	}
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return
+	}
+	key := converter.BuildKey(pod)
+	if !p.operations.tryBegin(key) {
+		return
+	}
+	defer p.operations.end(key)
+
+	// pod might be created since it was found missing
+	_, err := p.c.Get(ctx, pod)
+	if !errors.IsPodNotFound(err) {
+		return
+	}
+	message := fmt.Sprintf("Recreating missing podman pod %s", key)
+	if !p.correct(ctx, pod, missingPodReason, message) {
+		return
+	}
+	err = p.createPod(ctx, pod.DeepCopy())
+	if err != nil {
+		log.G(ctx).Warnf("error while recreating podman pod %s: %v", key, err)
+	}
+}
+
+// correct reports corrective action of reconcile as event of the object. It
+// returns false in dry run mode, when the action must not be done
+func (p *PodmanV0Provider) correct(ctx context.Context, object runtime.Object, reason, message string) bool {
+	if p.config.ReconcileDryRun {
+		message = "Dry run: " + message
+	}
+	log.G(ctx).Infof("%s: %s", reason, message)
+	if p.eventRecorder != nil {
+		p.eventRecorder.Event(object, v1.EventTypeWarning, reason, message)
+	}
+	return !p.config.ReconcileDryRun
+}
+
+// nodeReference returns reference of the node used for events of deleted
+// pods, same as used by kubelet
+func (p *PodmanV0Provider) nodeReference() *v1.ObjectReference {
+	return &v1.ObjectReference{
+		Kind: "Node",
+		Name: p.nodeName,
+		UID:  types.UID(p.nodeName),
 	}
 }
@@ -0,0 +1,65 @@
+package podman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPodOperations(t *testing.T) {
+	o := newPodOperations()
+	if !o.tryBegin("ns-pod") {
+		t.Fatalf("got false, want true")
+	}
+	if o.tryBegin("ns-pod") {
+		t.Errorf("got true, want false")
+	}
+	o.begin("ns-pod")
+	o.end("ns-pod")
+	o.end("ns-pod")
+	if !o.tryBegin("ns-pod") {
+		t.Errorf("got false, want true")
+	}
+	o.end("ns-pod")
+	if len(o.pods) != 0 {
+		t.Errorf("got %v, want no pods", o.pods)
+	}
+}
+
+func TestPodLocks(t *testing.T) {
+	l := newPodLocks()
+	l.lock("ns-a")
+
+	// other pods are not blocked
+	done := make(chan struct{})
+	go func() {
+		l.lock("ns-b")
+		l.unlock("ns-b")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("lock of another pod blocked")
+	}
+
+	// same pod waits for unlock
+	locked := make(chan struct{})
+	unlocked := make(chan struct{})
+	go func() {
+		l.lock("ns-a")
+		close(locked)
+		l.unlock("ns-a")
+		close(unlocked)
+	}()
+	select {
+	case <-locked:
+		t.Fatalf("lock of the same pod not blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.unlock("ns-a")
+	<-unlocked
+
+	if len(l.pods) != 0 {
+		t.Errorf("got %v, want no pods", l.pods)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/virtual-kubelet/podman/pkg/manager"
 )
@@ -70,6 +71,7 @@ type InitConfig struct {
 	DaemonPort        int32
 	KubeClusterDomain string
 	ResourceManager   *manager.ResourceManager
+	EventRecorder     record.EventRecorder
 }
 
 type InitFunc func(InitConfig) (Provider, error)
@@ -1,16 +1,49 @@
 package errors
 
 import (
+	"strings"
+
 	"github.com/virtual-kubelet/podman/pkg/iopodman"
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 )
 
 // VKError takes in varlink error and returns Virtual kubelet error
 func VKError(err error) error {
-	switch err {
-	case err.(*iopodman.PodNotFound):
-		return errdefs.NotFound("ImageNotFound")
+	switch err.(type) {
+	case *iopodman.PodNotFound:
+		return errdefs.AsNotFound(err)
+	case *iopodman.ContainerNotFound:
+		return errdefs.NotFound("ContainerNotFound")
 	default:
 		return errdefs.AsNotFound(err)
 	}
 }
+
+// IsPodNotFound returns true if podman reports the pod missing or without
+// containers. VKError reports any error as not found, so the original
+// varlink error is checked
+func IsPodNotFound(err error) bool {
+	switch cause(err).(type) {
+	case *iopodman.PodNotFound, *iopodman.NoContainersInPod:
+		return true
+	}
+	return false
+}
+
+// IsAlreadyExists returns true if podman refused to create the pod, as pod
+// of the same name exists
+func IsAlreadyExists(err error) bool {
+	e, ok := cause(err).(*iopodman.ErrorOccurred)
+	return ok && strings.Contains(e.Reason, "already exists")
+}
+
+// cause returns the varlink error wrapped by VKError
+func cause(err error) error {
+	for {
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return err
+		}
+		err = c.Cause()
+	}
+}
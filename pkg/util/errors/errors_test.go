@@ -0,0 +1,59 @@
+package errors
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+
+	"github.com/virtual-kubelet/podman/pkg/iopodman"
+)
+
+func TestIsPodNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"pod not found", VKError(&iopodman.PodNotFound{Name: "ns-pod"}), true},
+		{"no containers", VKError(&iopodman.NoContainersInPod{Name: "ns-pod"}), true},
+		{"container not found", VKError(&iopodman.ContainerNotFound{Id: "ns-pod-app"}), false},
+		{"connection error", VKError(fmt.Errorf("connection reset by peer")), false},
+		{"error occurred", VKError(&iopodman.ErrorOccurred{Reason: "no such pod"}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPodNotFound(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAlreadyExists(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"pod exists", VKError(&iopodman.ErrorOccurred{Reason: "error adding pod to state: name ns-pod is in use: pod already exists"}), true},
+		{"other reason", VKError(&iopodman.ErrorOccurred{Reason: "no space left on device"}), false},
+		{"other error", VKError(fmt.Errorf("pod already exists")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAlreadyExists(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVKError(t *testing.T) {
+	err := VKError(&iopodman.PodNotFound{Name: "ns-pod"})
+	if !errdefs.IsNotFound(err) {
+		t.Errorf("got %v, want not found", err)
+	}
+}
@@ -0,0 +1,49 @@
+package volume
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// ConfigMapPayload returns files of the configMap volume. Keys missing in
+// the configMap are skipped if volume is optional
+func ConfigMapPayload(source *v1.ConfigMapVolumeSource, configMap *v1.ConfigMap) (map[string]File, error) {
+	defaultMode := DefaultMode
+	if source.DefaultMode != nil {
+		defaultMode = *source.DefaultMode
+	}
+	optional := source.Optional != nil && *source.Optional
+
+	payload := make(map[string]File, len(configMap.Data)+len(configMap.BinaryData))
+	if len(source.Items) == 0 {
+		for name, data := range configMap.Data {
+			payload[name] = File{Data: []byte(data), Mode: defaultMode}
+		}
+		for name, data := range configMap.BinaryData {
+			payload[name] = File{Data: data, Mode: defaultMode}
+		}
+		return payload, nil
+	}
+
+	for _, item := range source.Items {
+		var file File
+		if data, ok := configMap.Data[item.Key]; ok {
+			file.Data = []byte(data)
+		} else if data, ok := configMap.BinaryData[item.Key]; ok {
+			file.Data = data
+		} else {
+			if optional {
+				continue
+			}
+			return nil, fmt.Errorf("configmap references non-existent config key: %s", item.Key)
+		}
+
+		file.Mode = defaultMode
+		if item.Mode != nil {
+			file.Mode = *item.Mode
+		}
+		payload[item.Path] = file
+	}
+	return payload, nil
+}
@@ -0,0 +1,42 @@
+package volume
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// SetOwnership makes the volume owned by the group, the same way kubelet
+// applies pod fsGroup. Directories get setgid bit, so files created later
+// inherit the group
+func SetOwnership(dir string, gid int64, readOnly bool) error {
+	mask := os.FileMode(0660)
+	if readOnly {
+		mask = 0440
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		err = os.Lchown(path, -1, int(gid))
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		mode := info.Mode() | mask
+		if info.IsDir() {
+			mode |= os.ModeSetgid | 0110
+		}
+		return os.Chmod(path, mode)
+	})
+}
+
+// GroupReadable makes payload files readable by the group owning the
+// volume, so rewritten payload matches mode set by SetOwnership
+func GroupReadable(payload map[string]File) {
+	for path, file := range payload {
+		file.Mode |= 0440
+		payload[path] = file
+	}
+}
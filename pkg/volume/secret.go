@@ -0,0 +1,42 @@
+package volume
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// SecretPayload returns files of the secret volume. Keys missing in the
+// secret are skipped if volume is optional
+func SecretPayload(source *v1.SecretVolumeSource, secret *v1.Secret) (map[string]File, error) {
+	defaultMode := DefaultMode
+	if source.DefaultMode != nil {
+		defaultMode = *source.DefaultMode
+	}
+	optional := source.Optional != nil && *source.Optional
+
+	payload := make(map[string]File, len(secret.Data))
+	if len(source.Items) == 0 {
+		for name, data := range secret.Data {
+			payload[name] = File{Data: data, Mode: defaultMode}
+		}
+		return payload, nil
+	}
+
+	for _, item := range source.Items {
+		data, ok := secret.Data[item.Key]
+		if !ok {
+			if optional {
+				continue
+			}
+			return nil, fmt.Errorf("secret references non-existent secret key: %s", item.Key)
+		}
+
+		file := File{Data: data, Mode: defaultMode}
+		if item.Mode != nil {
+			file.Mode = *item.Mode
+		}
+		payload[item.Path] = file
+	}
+	return payload, nil
+}
@@ -0,0 +1,70 @@
+package volume
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// tmpfsMagic is filesystem type of tmpfs reported by statfs
+const tmpfsMagic = 0x01021994
+
+// MountTmpfs mounts memory backed filesystem to dir, so volume content is
+// never written to the disk. Size limits the filesystem in bytes, zero
+// means default size. Dir with tmpfs already mounted is left as is
+func MountTmpfs(dir string, size int64) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	mounted, err := isTmpfsMount(dir)
+	if err != nil || mounted {
+		return err
+	}
+	options := "mode=0755"
+	if size > 0 {
+		options += fmt.Sprintf(",size=%d", size)
+	}
+	return syscall.Mount("tmpfs", dir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, options)
+}
+
+// UnmountTmpfs unmounts tmpfs from dir. Missing or not mounted dir is
+// ignored, e.g. when state directory itself is on tmpfs
+func UnmountTmpfs(dir string) error {
+	mounted, err := isTmpfsMount(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil || !mounted {
+		return err
+	}
+	err = syscall.Unmount(dir, 0)
+	if err == syscall.EINVAL {
+		// dir is not a mount point anymore
+		return nil
+	}
+	return err
+}
+
+// isTmpfsMount returns true if tmpfs is mounted on dir. Dir on tmpfs of its
+// parent directory is not a mount point
+func isTmpfsMount(dir string) (bool, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(dir, &stat)
+	if err != nil {
+		return false, &os.PathError{Op: "statfs", Path: dir, Err: err}
+	}
+	if stat.Type != tmpfsMagic {
+		return false, nil
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return false, err
+	}
+	parent, err := os.Stat(filepath.Dir(filepath.Clean(dir)))
+	if err != nil {
+		return false, err
+	}
+	return info.Sys().(*syscall.Stat_t).Dev != parent.Sys().(*syscall.Stat_t).Dev, nil
+}
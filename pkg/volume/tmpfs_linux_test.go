@@ -0,0 +1,67 @@
+package volume
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnmountTmpfsNotMounted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tmpfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		dir  string
+	}{
+		{"missing", filepath.Join(dir, "missing")},
+		{"not mounted", dir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := UnmountTmpfs(tt.dir); err != nil {
+				t.Errorf("got error %v", err)
+			}
+		})
+	}
+}
+
+func TestIsTmpfsMount(t *testing.T) {
+	shm, err := ioutil.TempDir("/dev/shm", "tmpfs")
+	if err != nil {
+		t.Skipf("tmpfs not available: %v", err)
+	}
+	defer os.RemoveAll(shm)
+	dir, err := ioutil.TempDir("", "tmpfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		dir  string
+		want bool
+	}{
+		{"directory on tmpfs", shm, false},
+		{"directory on disk", dir, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := isTmpfsMount(tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if err := UnmountTmpfs(tt.dir); err != nil {
+				t.Errorf("got unmount error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package volume
+
+import "fmt"
+
+// MountTmpfs is not supported on this platform
+func MountTmpfs(dir string, size int64) error {
+	return fmt.Errorf("tmpfs volumes are not supported on this platform")
+}
+
+// UnmountTmpfs is not supported on this platform
+func UnmountTmpfs(dir string) error {
+	return nil
+}
@@ -0,0 +1,198 @@
+// Package volume materialises kubernetes volumes on the podman node
+package volume
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// dataDirName is symlink to the directory with current volume data
+	dataDirName = "..data"
+	// dataDirTmpName is temporary symlink swapped into dataDirName
+	dataDirTmpName = "..data_tmp"
+	// DefaultMode is mode of the volume files if not set in the spec
+	DefaultMode int32 = 0644
+)
+
+// File is content and mode of the file in the volume
+type File struct {
+	Data []byte
+	Mode int32
+}
+
+// Write atomically replaces content of the volume directory with payload,
+// the same way kubelet does. Files are written into the timestamped
+// directory, which is swapped in by renaming ..data symlink. Top level
+// paths are symlinks into ..data. Directory is not touched when payload
+// did not change
+func Write(dir string, payload map[string]File) error {
+	for path := range payload {
+		if err := validatePath(path); err != nil {
+			return err
+		}
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	oldTsDir, err := os.Readlink(filepath.Join(dir, dataDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if oldTsDir != "" {
+		changed, err := payloadChanged(filepath.Join(dir, dataDirName), payload)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+	}
+
+	tsDir, err := ioutil.TempDir(dir, time.Now().UTC().Format("..2006_01_02_15_04_05."))
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tsDir, 0755)
+	if err != nil {
+		return err
+	}
+	for path, file := range payload {
+		err = writeFile(filepath.Join(tsDir, path), file)
+		if err != nil {
+			os.RemoveAll(tsDir)
+			return err
+		}
+	}
+
+	// swap data directory
+	tmpLink := filepath.Join(dir, dataDirTmpName)
+	err = os.Remove(tmpLink)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Symlink(filepath.Base(tsDir), tmpLink)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpLink, filepath.Join(dir, dataDirName))
+	if err != nil {
+		return err
+	}
+
+	// create user visible paths and remove paths, which are not in the
+	// payload anymore
+	names := map[string]bool{}
+	for path := range payload {
+		names[strings.SplitN(path, string(os.PathSeparator), 2)[0]] = true
+	}
+	for name := range names {
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			err = os.Symlink(filepath.Join(dataDirName, name), link)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") || names[entry.Name()] {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	if oldTsDir != "" {
+		return os.RemoveAll(filepath.Join(dir, oldTsDir))
+	}
+	return nil
+}
+
+// validatePath checks that payload path stays inside of the volume
+func validatePath(path string) error {
+	if path == "" {
+		return fmt.Errorf("invalid path: must not be empty")
+	}
+	if filepath.IsAbs(path) {
+		return fmt.Errorf("invalid path %s: must be relative path", path)
+	}
+	for _, item := range strings.Split(path, string(os.PathSeparator)) {
+		if item == ".." {
+			return fmt.Errorf("invalid path %s: must not contain '..'", path)
+		}
+	}
+	if strings.HasPrefix(path, "..") {
+		return fmt.Errorf("invalid path %s: must not start with '..'", path)
+	}
+	return nil
+}
+
+// writeFile writes file of the payload with its mode
+func writeFile(path string, file File) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path, file.Data, os.FileMode(file.Mode))
+	if err != nil {
+		return err
+	}
+	// file mode is not applied by WriteFile on existing file and umask
+	return os.Chmod(path, os.FileMode(file.Mode))
+}
+
+// payloadChanged compares payload with files in the data directory
+func payloadChanged(dataDir string, payload map[string]File) (bool, error) {
+	count := 0
+	err := filepath.Walk(dataDir+string(os.PathSeparator), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if count != len(payload) {
+		return true, nil
+	}
+
+	for path, file := range payload {
+		info, err := os.Stat(filepath.Join(dataDir, path))
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if info.Mode().Perm() != os.FileMode(file.Mode).Perm() {
+			return true, nil
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dataDir, path))
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(data, file.Data) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,125 @@
+package volume
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"key", false},
+		{"dir/key", false},
+		{"..key", true},
+		{"", true},
+		{"/etc/passwd", true},
+		{"dir/../../key", true},
+		{"..data/key", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			err := validatePath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		payloads []map[string]File
+	}{
+		{
+			name: "single",
+			payloads: []map[string]File{
+				{"key": {Data: []byte("value"), Mode: DefaultMode}},
+			},
+		},
+		{
+			name: "update",
+			payloads: []map[string]File{
+				{"key": {Data: []byte("value"), Mode: DefaultMode}, "old": {Data: []byte("old"), Mode: DefaultMode}},
+				{"key": {Data: []byte("changed"), Mode: 0400}, "dir/new": {Data: []byte("new"), Mode: DefaultMode}},
+			},
+		},
+		{
+			name: "unchanged",
+			payloads: []map[string]File{
+				{"key": {Data: []byte("value"), Mode: DefaultMode}},
+				{"key": {Data: []byte("value"), Mode: DefaultMode}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "volume")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			for _, payload := range tt.payloads {
+				if err := Write(dir, payload); err != nil {
+					t.Fatalf("error writing payload: %v", err)
+				}
+			}
+
+			payload := tt.payloads[len(tt.payloads)-1]
+			for path, file := range payload {
+				data, err := ioutil.ReadFile(filepath.Join(dir, path))
+				if err != nil {
+					t.Fatalf("error reading %s: %v", path, err)
+				}
+				if string(data) != string(file.Data) {
+					t.Errorf("got %s content %q, want %q", path, data, file.Data)
+				}
+				info, err := os.Stat(filepath.Join(dir, path))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm() != os.FileMode(file.Mode) {
+					t.Errorf("got %s mode %v, want %v", path, info.Mode().Perm(), os.FileMode(file.Mode))
+				}
+			}
+
+			entries, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var visible, data int
+			for _, entry := range entries {
+				switch {
+				case entry.Name() == dataDirName:
+				case entry.Name()[0] == '.':
+					data++
+				default:
+					visible++
+				}
+			}
+			if data != 1 {
+				t.Errorf("got %d data directories, want 1", data)
+			}
+			if want := len(topLevel(payload)); visible != want {
+				t.Errorf("got %d top level paths, want %d", visible, want)
+			}
+		})
+	}
+}
+
+// topLevel returns top level paths of the payload
+func topLevel(payload map[string]File) map[string]bool {
+	names := map[string]bool{}
+	for path := range payload {
+		for filepath.Dir(path) != "." {
+			path = filepath.Dir(path)
+		}
+		names[path] = true
+	}
+	return names
+}